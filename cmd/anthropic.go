@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultAnthropicURL = "https://api.anthropic.com"
+	anthropicVersion    = "2023-06-01"
+	anthropicMaxTokens  = 1024
+)
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	MaxTokens int                `json:"max_tokens"`
+	Stream    bool               `json:"stream,omitempty"`
+
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+type anthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+type anthropicResponse struct {
+	Model      string                  `json:"model"`
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// anthropicStreamEvent covers the fields of the streaming events we use:
+// message_start, content_block_delta, message_delta and error.
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Model string `json:"model"`
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type anthropicErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicProvider talks to the Anthropic Messages API.
+type anthropicProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+func newAnthropicProvider(baseURL, apiKey string, client *http.Client) *anthropicProvider {
+	return &anthropicProvider{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, client: client}
+}
+
+func (p *anthropicProvider) Name() string {
+	return "anthropic"
+}
+
+func (p *anthropicProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+
+	var msgResp anthropicResponse
+	err := postJSON(ctx, p.client, p.Name(), p.baseURL+"/v1/messages", headers, toAnthropicRequest(req), &msgResp)
+	if err != nil {
+		return nil, p.mapError(err)
+	}
+
+	var text strings.Builder
+	for _, block := range msgResp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return nil, &ProviderError{Provider: p.Name(), Kind: ErrEmptyResponse, Message: "no text content returned"}
+	}
+
+	completion := &Completion{
+		Content:  text.String(),
+		Provider: p.Name(),
+		Model:    req.Model,
+		Usage: Usage{
+			PromptTokens:     msgResp.Usage.InputTokens,
+			CompletionTokens: msgResp.Usage.OutputTokens,
+		},
+	}
+	if msgResp.Model != "" {
+		completion.Model = msgResp.Model
+	}
+	return completion, nil
+}
+
+func (p *anthropicProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (*Completion, error) {
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+	reqBody := toAnthropicRequest(req)
+	reqBody.Stream = true
+	completion := &Completion{Provider: p.Name(), Model: req.Model}
+
+	var content strings.Builder
+	err := postStream(ctx, p.client, p.Name(), p.baseURL+"/v1/messages", headers, reqBody, func(line []byte) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var event anthropicStreamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return &ProviderError{Provider: p.Name(), Kind: ErrUnknown, Message: fmt.Sprintf("invalid stream event: %v", err)}
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message.Model != "" {
+				completion.Model = event.Message.Model
+			}
+			completion.Usage.PromptTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				content.WriteString(event.Delta.Text)
+				onDelta(event.Delta.Text)
+			}
+		case "message_delta":
+			completion.Usage.CompletionTokens = event.Usage.OutputTokens
+		case "error":
+			body, _ := json.Marshal(anthropicErrorResponse{Error: event.Error})
+			return &ProviderError{Provider: p.Name(), Kind: ErrServer, Message: string(body)}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, p.mapError(err)
+	}
+	if content.Len() == 0 {
+		return nil, &ProviderError{Provider: p.Name(), Kind: ErrEmptyResponse, Message: "no text content returned"}
+	}
+	completion.Content = content.String()
+	return completion, nil
+}
+
+// toAnthropicRequest moves system messages into the top-level system field
+// and wraps the rest in text content blocks. The API has no seed parameter.
+func toAnthropicRequest(req CompletionRequest) anthropicRequest {
+	out := anthropicRequest{
+		Model:         req.Model,
+		MaxTokens:     anthropicMaxTokens,
+		Temperature:   req.Sampling.Temperature,
+		TopP:          req.Sampling.TopP,
+		StopSequences: req.Sampling.Stop,
+	}
+	if req.Sampling.MaxTokens > 0 {
+		out.MaxTokens = req.Sampling.MaxTokens
+	}
+	var system []string
+	for _, msg := range req.Messages {
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+			continue
+		}
+		out.Messages = append(out.Messages, anthropicMessage{
+			Role:    msg.Role,
+			Content: []anthropicContentBlock{{Type: "text", Text: msg.Content}},
+		})
+	}
+	out.System = strings.Join(system, "\n\n")
+	return out
+}
+
+// mapError replaces the raw error body with Anthropic's message and uses the
+// error type, which is more precise than the status code.
+func (p *anthropicProvider) mapError(err error) error {
+	var perr *ProviderError
+	if !errors.As(err, &perr) {
+		return err
+	}
+
+	var body anthropicErrorResponse
+	if json.Unmarshal([]byte(perr.Message), &body) != nil || body.Error.Message == "" {
+		return perr
+	}
+	perr.Message = body.Error.Message
+	switch body.Error.Type {
+	case "authentication_error", "permission_error":
+		perr.Kind = ErrAuth
+	case "rate_limit_error":
+		perr.Kind = ErrRateLimited
+	case "overloaded_error", "api_error":
+		perr.Kind = ErrServer
+	case "not_found_error":
+		perr.Kind = ErrModelNotFound
+	case "invalid_request_error":
+		perr.Kind = ErrBadRequest
+	}
+	return perr
+}
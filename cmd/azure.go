@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const defaultAzureAPIVersion = "2024-10-21"
+
+type azureErrorResponse struct {
+	Error struct {
+		Code       string `json:"code"`
+		Message    string `json:"message"`
+		InnerError struct {
+			Code string `json:"code"`
+		} `json:"innererror"`
+	} `json:"error"`
+}
+
+// azureProvider talks to an Azure OpenAI deployment. Azure speaks the OpenAI
+// dialect but routes by deployment rather than model name.
+type azureProvider struct {
+	*openAIProvider
+}
+
+// azureDeploymentURL builds the chat completions URL for a deployment.
+func azureDeploymentURL(resource, deployment, apiVersion string) string {
+	return fmt.Sprintf("https://%s.openai.azure.com/openai/deployments/%s/chat/completions?api-version=%s",
+		url.PathEscape(resource), url.PathEscape(deployment), url.QueryEscape(apiVersion))
+}
+
+func newAzureProvider(resource, deployment, apiVersion, apiKey string, client *http.Client) *azureProvider {
+	endpoint := azureDeploymentURL(resource, deployment, apiVersion)
+	return &azureProvider{newOpenAIProvider("azure", endpoint, "api-key", apiKey, nil, client)}
+}
+
+func (p *azureProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	completion, err := p.openAIProvider.Complete(ctx, req)
+	if err != nil {
+		return nil, mapAzureError(err)
+	}
+	return completion, nil
+}
+
+func (p *azureProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (*Completion, error) {
+	completion, err := p.openAIProvider.Stream(ctx, req, onDelta)
+	if err != nil {
+		return nil, mapAzureError(err)
+	}
+	return completion, nil
+}
+
+// mapAzureError unwraps Azure's error bodies and reports content filter
+// rejections as blocked requests.
+func mapAzureError(err error) error {
+	var perr *ProviderError
+	if !errors.As(err, &perr) {
+		return err
+	}
+	var body azureErrorResponse
+	if json.Unmarshal([]byte(perr.Message), &body) != nil || body.Error.Message == "" {
+		return perr
+	}
+	perr.Message = body.Error.Message
+	switch {
+	case body.Error.Code == "content_filter" || body.Error.InnerError.Code == "ResponsibleAIPolicyViolation":
+		perr.Kind = ErrBlocked
+		perr.Message = "blocked by Azure content filter: " + body.Error.Message
+	case body.Error.Code == "DeploymentNotFound":
+		perr.Kind = ErrModelNotFound
+	}
+	return perr
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	defaultCacheTTL        = 24 * time.Hour
+	defaultCacheMaxEntries = 200
+)
+
+// noCache is set by --no-cache to skip cache lookups for this run.
+var noCache bool
+
+type cacheBypassKey struct{}
+
+// withCacheBypass returns a context whose generations skip cache lookups.
+// Fresh completions are still stored.
+func withCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return noCache || bypass
+}
+
+type cacheEntry struct {
+	Created  time.Time `json:"created"`
+	Content  string    `json:"content"`
+	Choices  []string  `json:"choices,omitempty"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+}
+
+func getCacheDir() string {
+	return filepath.Join(getConfigDir(), "cache")
+}
+
+// cacheKey hashes everything that affects a completion: the provider and the
+// full request, which holds the model, prompt and staged diff.
+func cacheKey(provider string, req CompletionRequest) string {
+	data, _ := json.Marshal(struct {
+		Provider string
+		Request  CompletionRequest
+	}{provider, req})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadCachedCompletion returns the cached completion for key, or nil if there
+// is none or it has expired.
+func loadCachedCompletion(key string) *Completion {
+	path := filepath.Join(getCacheDir(), key+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if json.Unmarshal(data, &entry) != nil {
+		return nil
+	}
+	if time.Since(entry.Created) > loadDurationSetting("cache_ttl", defaultCacheTTL) {
+		os.Remove(path)
+		return nil
+	}
+	return &Completion{Content: entry.Content, Choices: entry.Choices, Provider: entry.Provider, Model: entry.Model, Cached: true}
+}
+
+// saveCachedCompletion stores completion under key and prunes the cache.
+// Failures are ignored; the cache is only an optimisation.
+func saveCachedCompletion(key string, completion *Completion) {
+	cacheDir := getCacheDir()
+	if err := os.MkdirAll(cacheDir, 0700); err != nil {
+		return
+	}
+	data, err := json.Marshal(cacheEntry{
+		Created:  time.Now(),
+		Content:  completion.Content,
+		Choices:  completion.Choices,
+		Provider: completion.Provider,
+		Model:    completion.Model,
+	})
+	if err != nil {
+		return
+	}
+	if os.WriteFile(filepath.Join(cacheDir, key+".json"), data, 0600) != nil {
+		return
+	}
+	pruneCache(cacheDir)
+}
+
+// pruneCache removes expired entries and then the oldest ones until at most
+// cache_max_entries remain.
+func pruneCache(cacheDir string) {
+	files, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+	if err != nil {
+		return
+	}
+	ttl := loadDurationSetting("cache_ttl", defaultCacheTTL)
+	maxEntries := loadIntSetting("cache_max_entries", defaultCacheMaxEntries)
+
+	type cacheFile struct {
+		path    string
+		modTime time.Time
+	}
+	var live []cacheFile
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > ttl {
+			os.Remove(path)
+			continue
+		}
+		live = append(live, cacheFile{path, info.ModTime()})
+	}
+
+	sort.Slice(live, func(i, j int) bool { return live[i].modTime.Before(live[j].modTime) })
+	for len(live) > maxEntries {
+		os.Remove(live[0].path)
+		live = live[1:]
+	}
+}
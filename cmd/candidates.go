@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultCandidates is how many messages the TUI asks for. Each extra
+// candidate costs about as much as the first, so more are opt-in.
+const defaultCandidates = 1
+
+type candidatesKey struct{}
+
+// withCandidates returns a context whose generations ask for n candidates.
+func withCandidates(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, candidatesKey{}, n)
+}
+
+// candidatesFor returns how many candidates generations under ctx ask for.
+func candidatesFor(ctx context.Context) int {
+	if n, ok := ctx.Value(candidatesKey{}).(int); ok && n > 1 {
+		return n
+	}
+	return 1
+}
+
+// completeExtra asks provider for n more candidates with parallel calls, for
+// providers that don't support several candidates per request. Failed calls
+// are skipped; the caller already has at least one candidate.
+func completeExtra(ctx context.Context, provider Provider, req CompletionRequest, n int) []string {
+	req.N = 1
+
+	var mu sync.Mutex
+	var extra []string
+	var g errgroup.Group
+	for i := 0; i < n; i++ {
+		g.Go(func() error {
+			completion, err := provider.Complete(ctx, req)
+			if err != nil {
+				return nil
+			}
+			mu.Lock()
+			extra = append(extra, completion.Content)
+			mu.Unlock()
+			return nil
+		})
+	}
+	g.Wait()
+	return extra
+}
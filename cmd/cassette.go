@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// cassette is a recorded exchange with a provider. The "replay" provider
+// answers requests from cassettes instead of the network, which lets
+// prompts be developed and the tool run without a connection.
+type cassette struct {
+	Recorded time.Time         `json:"recorded"`
+	Provider string            `json:"provider"`
+	Request  CompletionRequest `json:"request"`
+	Response cassetteResponse  `json:"response"`
+}
+
+type cassetteResponse struct {
+	Content string   `json:"content"`
+	Choices []string `json:"choices,omitempty"`
+	Model   string   `json:"model"`
+}
+
+func getCassetteDir() string {
+	return loadSetting("cassette_dir", filepath.Join(getConfigDir(), "cassettes"))
+}
+
+// cassetteKey names the cassette for req. Unlike cacheKey it leaves out the
+// provider so an exchange recorded with any provider can be replayed, and
+// it is computed before secrets are stripped so redaction does not change
+// which requests match.
+func cassetteKey(req CompletionRequest) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordingCassettes reports whether completions should be recorded.
+func recordingCassettes() bool {
+	return loadSetting("record_cassettes", "false") == "true"
+}
+
+// saveCassette records completion as the answer to req, with secrets
+// stripped from the prompt and the answer.
+func saveCassette(provider string, req CompletionRequest, completion *Completion) error {
+	key := cassetteKey(req)
+
+	redacted := req
+	redacted.Messages = make([]Message, len(req.Messages))
+	for i, msg := range req.Messages {
+		redacted.Messages[i] = Message{Role: msg.Role, Content: redactSecrets(msg.Content)}
+	}
+	response := cassetteResponse{Content: redactSecrets(completion.Content), Model: completion.Model}
+	for _, choice := range completion.Choices {
+		response.Choices = append(response.Choices, redactSecrets(choice))
+	}
+
+	data, err := json.MarshalIndent(cassette{
+		Recorded: time.Now(),
+		Provider: provider,
+		Request:  redacted,
+		Response: response,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := getCassetteDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, key+".json"), data, 0644)
+}
+
+// secretPatterns match credentials that may turn up in a staged diff: API
+// keys of the supported providers, bearer tokens and key assignments.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_-]{16,}`),
+	regexp.MustCompile(`AIza[0-9A-Za-z_-]{35}`),
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]{16,}`),
+	regexp.MustCompile(`(?i)((?:api[_-]?key|secret|token|password)["']?\s*[:=]\s*["']?)[^\s"']{8,}`),
+}
+
+// knownKeys returns every API key commiter may have resolved: from
+// COMMITER_API_KEY, the providers' variables, their key files and the key
+// commands run so far.
+func knownKeys() []string {
+	keys := []string{os.Getenv("COMMITER_API_KEY")}
+	for _, cred := range credentials {
+		for _, name := range cred.env {
+			keys = append(keys, os.Getenv(name))
+		}
+		keys = append(keys, loadSetting(cred.file, ""))
+	}
+	keyCommandMu.Lock()
+	for _, key := range keyCommandResults {
+		keys = append(keys, key)
+	}
+	keyCommandMu.Unlock()
+	return keys
+}
+
+// redactSecrets replaces the known API keys and anything that looks like a
+// credential in text.
+func redactSecrets(text string) string {
+	for _, key := range knownKeys() {
+		if key = strings.TrimSpace(key); len(key) >= 8 {
+			text = strings.ReplaceAll(text, key, "[REDACTED]")
+		}
+	}
+	for _, pattern := range secretPatterns {
+		if pattern.NumSubexp() > 0 {
+			text = pattern.ReplaceAllString(text, "${1}[REDACTED]")
+		} else {
+			text = pattern.ReplaceAllString(text, "[REDACTED]")
+		}
+	}
+	return text
+}
+
+// replayProvider answers from recorded cassettes and never touches the
+// network. A request without a cassette fails.
+type replayProvider struct {
+	dir string
+}
+
+func newReplayProvider(dir string) *replayProvider {
+	return &replayProvider{dir: dir}
+}
+
+func (p *replayProvider) Name() string {
+	return "replay"
+}
+
+func (p *replayProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	key := cassetteKey(req)
+	data, err := os.ReadFile(filepath.Join(p.dir, key+".json"))
+	if err != nil {
+		return nil, &ProviderError{
+			Provider: p.Name(),
+			Kind:     ErrBadRequest,
+			Message:  fmt.Sprintf("no cassette %s.json in %s; record one with record_cassettes", key, p.dir),
+		}
+	}
+	var recorded cassette
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, &ProviderError{Provider: p.Name(), Kind: ErrUnknown, Message: fmt.Sprintf("invalid cassette %s.json: %v", key, err)}
+	}
+	return &Completion{
+		Content:  recorded.Response.Content,
+		Choices:  recorded.Response.Choices,
+		Provider: p.Name(),
+		Model:    recorded.Response.Model,
+	}, nil
+}
+
+// Stream replays the whole answer as a single delta.
+func (p *replayProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (*Completion, error) {
+	completion, err := p.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	onDelta(completion.Content)
+	return completion, nil
+}
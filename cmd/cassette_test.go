@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRedactSecretsStripsResolvedKeys(t *testing.T) {
+	useTestConfig(t, map[string]string{
+		"provider":                  "openrouter",
+		"anthropic_api_key_command": "echo commandkey0123456",
+	})
+	clearKeyEnv(t)
+	t.Setenv("COMMITER_API_KEY", "envkey0123456")
+	if _, err := loadProviderKey("anthropic"); err != nil {
+		t.Fatalf("loadProviderKey: %v", err)
+	}
+
+	got := redactSecrets("key1 = envkey0123456\nkey2 := commandkey0123456\n")
+	for _, secret := range []string{"envkey0123456", "commandkey0123456"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("redactSecrets left %q in %q", secret, got)
+		}
+	}
+}
+
+// initTestRepo creates a git repo with one staged file in a temporary
+// directory and makes it the working directory for the rest of the test.
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(repo); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git("init", "-q")
+	git("config", "user.name", "Test")
+	git("config", "user.email", "test@example.com")
+	git("config", "commit.gpgsign", "false")
+	if err := os.WriteFile("greet.go", []byte("package main\n\nfunc greet() string { return \"hello\" }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "greet.go")
+	return repo
+}
+
+func TestReplayGenerateAndCommit(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"model":"qwen2.5-coder","choices":[{"message":{"content":"feat: add greet"}}],"usage":{"prompt_tokens":40,"completion_tokens":4}}`))
+	}))
+	defer server.Close()
+	useTestConfig(t, map[string]string{
+		"provider":         "openai-compatible",
+		"model":            "qwen2.5-coder",
+		"openai_base_url":  server.URL,
+		"cassette_dir":     t.TempDir(),
+		"record_cassettes": "true",
+	})
+	clearKeyEnv(t)
+	initTestRepo(t)
+
+	if message := generateCommitMessage(context.Background(), true, generationHooks{}); message != "feat: add greet" {
+		t.Fatalf("recording: generateCommitMessage = %q, want the server's message", message)
+	}
+
+	// Replay offline: the server is gone and only the cassette can answer
+	server.Close()
+	saveProviderName("replay")
+	saveSetting("record_cassettes", "false")
+	message := generateCommitMessage(context.Background(), true, generationHooks{})
+	if message != "feat: add greet" {
+		t.Fatalf("replaying: generateCommitMessage = %q, want the recorded message", message)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("server got %d requests, want only the recorded one", hits.Load())
+	}
+	if result := performCommit(message, true); result != "Committed successfully." {
+		t.Fatalf("performCommit = %q", result)
+	}
+
+	out, err := exec.Command("git", "log", "-1", "--format=%s").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject := strings.TrimSpace(string(out)); subject != "feat: add greet" {
+		t.Errorf("commit subject = %q, want %q", subject, "feat: add greet")
+	}
+}
+
+func TestReplayMissingCassette(t *testing.T) {
+	cassettes := t.TempDir()
+	useTestConfig(t, map[string]string{
+		"provider":     "replay",
+		"cassette_dir": cassettes,
+	})
+
+	_, err := newReplayProvider(cassettes).Complete(context.Background(), testRequest())
+	var perr *ProviderError
+	if !errors.As(err, &perr) {
+		t.Fatalf("err = %v, want a *ProviderError", err)
+	}
+	if perr.Provider != "replay" || !strings.HasPrefix(perr.Message, "no cassette ") {
+		t.Errorf("err = %+v, want a replay error naming the missing cassette", perr)
+	}
+
+	initTestRepo(t)
+	message := generateCommitMessage(context.Background(), true, generationHooks{})
+	if !strings.HasPrefix(message, "API error: no cassette ") {
+		t.Errorf("generateCommitMessage = %q, want the missing cassette error", message)
+	}
+}
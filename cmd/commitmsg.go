@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CommitMessage is a conventional commit message as returned in structured
+// mode. Options are the alternative description lines the detailed prompt
+// asks for.
+type CommitMessage struct {
+	Type     string   `json:"type"`
+	Scope    string   `json:"scope"`
+	Subject  string   `json:"subject"`
+	Body     string   `json:"body"`
+	Breaking bool     `json:"breaking"`
+	Options  []string `json:"options"`
+}
+
+var commitTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+// commitMessageSchema is the JSON schema of CommitMessage. Every property is
+// required, as strict structured output modes demand.
+var commitMessageSchema = &ResponseSchema{
+	Name: "commit_message",
+	Schema: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"type":     map[string]any{"type": "string", "enum": commitTypes, "description": "Conventional commit type"},
+			"scope":    map[string]any{"type": "string", "description": "Optional scope, or an empty string"},
+			"subject":  map[string]any{"type": "string", "description": "Short imperative summary of the change"},
+			"body":     map[string]any{"type": "string", "description": "Optional longer explanation, or an empty string"},
+			"breaking": map[string]any{"type": "boolean", "description": "Whether the change breaks compatibility"},
+			"options":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Up to 3 alternative description lines"},
+		},
+		"required":             []string{"type", "scope", "subject", "body", "breaking", "options"},
+		"additionalProperties": false,
+	},
+}
+
+// commitHeaderPattern matches "type(scope)!: subject".
+var commitHeaderPattern = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+
+// String renders the message in the format the detailed prompt asks for:
+// the header, the option lines, then the body.
+func (c CommitMessage) String() string {
+	var b strings.Builder
+	b.WriteString(c.Type)
+	if c.Scope != "" {
+		b.WriteString("(" + c.Scope + ")")
+	}
+	if c.Breaking {
+		b.WriteString("!")
+	}
+	b.WriteString(": " + c.Subject)
+	for _, option := range c.Options {
+		b.WriteString("\n- " + option)
+	}
+	if c.Body != "" {
+		b.WriteString("\n\n" + c.Body)
+	}
+	return b.String()
+}
+
+// Validate checks that the message is usable as a conventional commit.
+func (c CommitMessage) Validate() error {
+	known := false
+	for _, t := range commitTypes {
+		if c.Type == t {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown type %q", c.Type)
+	}
+	if strings.TrimSpace(c.Subject) == "" {
+		return fmt.Errorf("empty subject")
+	}
+	if strings.Contains(c.Subject, "\n") {
+		return fmt.Errorf("subject spans several lines")
+	}
+	return nil
+}
+
+// parseCommitMessage reads a model's answer as JSON or, for models without a
+// JSON mode, as "type(scope): subject" text with "- option" lines. fromJSON
+// tells which format matched; ok is false if the text follows neither.
+func parseCommitMessage(text string) (msg CommitMessage, fromJSON, ok bool) {
+	text = strings.TrimSpace(text)
+	jsonText := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(text, "```json"), "```"), "```")
+	if json.Unmarshal([]byte(jsonText), &msg) == nil && msg.Subject != "" {
+		return msg, true, true
+	}
+
+	lines := strings.Split(text, "\n")
+	match := commitHeaderPattern.FindStringSubmatch(strings.TrimSpace(lines[0]))
+	if match == nil {
+		return CommitMessage{}, false, false
+	}
+	msg = CommitMessage{
+		Type:     strings.ToLower(match[1]),
+		Scope:    match[2],
+		Breaking: match[3] == "!",
+		Subject:  strings.TrimSpace(match[4]),
+	}
+	var body []string
+	for _, line := range lines[1:] {
+		if option, found := strings.CutPrefix(strings.TrimSpace(line), "- "); found && len(body) == 0 {
+			msg.Options = append(msg.Options, option)
+			continue
+		}
+		body = append(body, line)
+	}
+	msg.Body = strings.TrimSpace(strings.Join(body, "\n"))
+	return msg, false, true
+}
+
+// formatCommitMessage parses, validates and renders one candidate. Only JSON
+// answers must validate; text that isn't a valid conventional commit, such
+// as "README: fix typo", is passed through unchanged like any other text.
+func formatCommitMessage(text string) (string, error) {
+	msg, fromJSON, ok := parseCommitMessage(text)
+	if !ok {
+		return text, nil
+	}
+	if err := msg.Validate(); err != nil {
+		if fromJSON {
+			return "", err
+		}
+		return strings.TrimSpace(text), nil
+	}
+	return msg.String(), nil
+}
+
+// requestCommitMessage is requestCompletion in structured mode: it asks for
+// JSON matching CommitMessage and returns the rendered message. Candidates
+// that fail validation are dropped. The raw answer is streamed as it
+// arrives; the returned message replaces it once it is complete.
+func requestCommitMessage(ctx context.Context, req CompletionRequest, hooks generationHooks) string {
+	inner := hooks
+	inner.onAnswer = nil
+
+	req.Schema = commitMessageSchema
+	completion, failure := completeChain(ctx, req, inner)
+	if completion == nil {
+		return failure
+	}
+
+	var valid []string
+	var invalid error
+	for _, candidate := range completion.candidates() {
+		message, err := formatCommitMessage(candidate)
+		if err != nil {
+			invalid = err
+			continue
+		}
+		valid = append(valid, message)
+	}
+	if len(valid) == 0 {
+		return fmt.Sprintf("Error: invalid commit message: %v", invalid)
+	}
+
+	rendered := *completion
+	rendered.Content = valid[0]
+	rendered.Choices = nil
+	if len(valid) > 1 {
+		rendered.Choices = valid
+	}
+	if hooks.onAnswer != nil {
+		hooks.onAnswer(&rendered)
+	}
+	return rendered.Content
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import "testing"
+
+func TestFormatCommitMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "json",
+			text: `{"type":"feat","scope":"cli","subject":"add models command","body":"","breaking":false,"options":["list models"]}`,
+			want: "feat(cli): add models command\n- list models",
+		},
+		{
+			name:    "json with unknown type",
+			text:    `{"type":"update","scope":"","subject":"bump deps","body":"","breaking":false,"options":[]}`,
+			wantErr: true,
+		},
+		{
+			name: "conventional text",
+			text: "fix: handle empty diff\n- skip the request",
+			want: "fix: handle empty diff\n- skip the request",
+		},
+		{
+			name: "text with a non-conventional prefix",
+			text: "README: fix typo\n",
+			want: "README: fix typo",
+		},
+		{
+			name: "plain text",
+			text: "Update dependencies",
+			want: "Update dependencies",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatCommitMessage(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("formatCommitMessage(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("formatCommitMessage(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
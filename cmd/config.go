@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadSetting reads the named file from the config dir, returning fallback
+// if it doesn't exist or is empty.
+func loadSetting(name, fallback string) string {
+	data, err := os.ReadFile(filepath.Join(getConfigDir(), name))
+	if err != nil {
+		return fallback
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// loadIntSetting reads an integer setting, returning fallback if it is
+// missing or malformed.
+func loadIntSetting(name string, fallback int) int {
+	value, err := strconv.Atoi(loadSetting(name, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// loadFloatSetting reads a decimal setting, returning fallback if it is
+// missing or malformed.
+func loadFloatSetting(name string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(loadSetting(name, ""), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// loadDurationSetting reads a duration setting such as "500ms" or "1m",
+// returning fallback if it is missing or malformed.
+func loadDurationSetting(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(loadSetting(name, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// saveSetting writes value to the named file in the config dir.
+func saveSetting(name, value string) {
+	configDir := getConfigDir()
+	os.MkdirAll(configDir, 0755)
+	err := os.WriteFile(filepath.Join(configDir, name), []byte(value), 0644)
+	if err != nil {
+		fmt.Printf("Error saving %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// loadHeadersSetting reads a file of "Name: value" lines into a header map.
+// Blank lines and lines starting with # are ignored.
+func loadHeadersSetting(name string) map[string]string {
+	headers := map[string]string{}
+	for _, line := range strings.Split(loadSetting(name, ""), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// credential names where a provider's API key can be found. The key file
+// is a setting in the config directory; the command setting is the file
+// name plus "_command".
+type credential struct {
+	file string
+	env  []string
+}
+
+var credentials = map[string]credential{
+	"openrouter": {file: "api_key", env: []string{"OPENROUTER_API_KEY"}},
+	"anthropic":  {file: "anthropic_api_key", env: []string{"ANTHROPIC_API_KEY"}},
+	"gemini":     {file: "gemini_api_key", env: []string{"GEMINI_API_KEY", "GOOGLE_API_KEY"}},
+	"azure":      {file: "azure_api_key", env: []string{"AZURE_OPENAI_API_KEY"}},
+	// No standard variable: an OpenAI key must not leak to a custom server
+	"openai-compatible": {file: "openai_api_key"},
+}
+
+// keyCommandTimeout bounds how long a key command such as a password
+// manager prompt may take.
+const keyCommandTimeout = 30 * time.Second
+
+// errNoAPIKey is returned when none of a provider's credentials is set.
+var errNoAPIKey = errors.New("no API key")
+
+var (
+	keyCommandMu      sync.Mutex
+	keyCommandResults = map[string]string{}
+)
+
+// loadProviderKey resolves the API key for provider, trying in order
+// COMMITER_API_KEY (for the selected provider only), the provider's
+// standard environment variables, its key command and its key file.
+func loadProviderKey(provider string) (string, error) {
+	cred, ok := credentials[provider]
+	if !ok {
+		return "", fmt.Errorf("provider %q does not use an API key", provider)
+	}
+
+	if key := strings.TrimSpace(os.Getenv("COMMITER_API_KEY")); key != "" && provider == loadProviderName() {
+		return key, nil
+	}
+	for _, name := range cred.env {
+		if key := strings.TrimSpace(os.Getenv(name)); key != "" {
+			return key, nil
+		}
+	}
+	if command := loadSetting(cred.file+"_command", ""); command != "" {
+		return runKeyCommand(cred.file+"_command", command)
+	}
+	if key := loadSetting(cred.file, ""); key != "" {
+		return key, nil
+	}
+	sources := append(append([]string{}, cred.env...), cred.file+"_command", cred.file)
+	return "", fmt.Errorf("%w for %s: set %s (or run 'commiter init')", errNoAPIKey, provider, strings.Join(sources, ", "))
+}
+
+// runKeyCommand runs command through the shell and returns its trimmed
+// output. Results are kept for the rest of the process so a password
+// manager is asked only once.
+func runKeyCommand(setting, command string) (string, error) {
+	keyCommandMu.Lock()
+	defer keyCommandMu.Unlock()
+	if key, ok := keyCommandResults[command]; ok {
+		return key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s failed: %v: %s", setting, err, msg)
+		}
+		return "", fmt.Errorf("%s failed: %v", setting, err)
+	}
+	// Password managers print the secret on the first line
+	key, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("%s printed no key", setting)
+	}
+	keyCommandResults[command] = key
+	return key, nil
+}
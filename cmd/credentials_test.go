@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOpenAICompatibleKeyErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		wantErr  string
+	}{
+		{name: "no key", settings: map[string]string{}},
+		{name: "key file", settings: map[string]string{"openai_api_key": "sk-test"}},
+		{
+			name:     "failing key command",
+			settings: map[string]string{"openai_api_key_command": "echo locked >&2; exit 1"},
+			wantErr:  "openai_api_key_command failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestConfig(t, tt.settings)
+			clearKeyEnv(t)
+			_, err := newProvider("openai-compatible", "qwen2.5-coder")
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("newProvider: %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("newProvider: %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
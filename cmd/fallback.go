@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"strings"
+)
+
+// chainEntry is one provider and model of the fallback chain.
+type chainEntry struct {
+	Provider string
+	Model    string
+}
+
+func (e chainEntry) String() string {
+	return e.Provider + ":" + e.Model
+}
+
+// loadFallbackChain reads the fallback_chain setting, one "provider:model"
+// per line, tried in order. Without it the chain is just the configured
+// provider and model.
+func loadFallbackChain() []chainEntry {
+	var chain []chainEntry
+	for _, line := range strings.Split(loadSetting("fallback_chain", ""), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Model names may contain colons themselves, e.g. ollama's "qwen2.5:7b"
+		provider, model, ok := strings.Cut(line, ":")
+		if !ok || model == "" {
+			model = loadModel()
+		}
+		chain = append(chain, chainEntry{Provider: strings.TrimSpace(provider), Model: strings.TrimSpace(model)})
+	}
+	if len(chain) == 0 {
+		chain = append(chain, chainEntry{Provider: loadProviderName(), Model: loadModel()})
+	}
+	return chain
+}
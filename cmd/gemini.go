@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const defaultGeminiURL = "https://generativelanguage.googleapis.com"
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Seed            *int     `json:"seed,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+}
+
+type geminiErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// geminiProvider talks to the Gemini generateContent REST API.
+type geminiProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+func newGeminiProvider(baseURL, apiKey string, client *http.Client) *geminiProvider {
+	return &geminiProvider{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, client: client}
+}
+
+func (p *geminiProvider) Name() string {
+	return "gemini"
+}
+
+func (p *geminiProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	var genResp geminiResponse
+	err := postJSON(ctx, p.client, p.Name(), p.endpoint(req.Model, "generateContent"), nil, toGeminiRequest(req), &genResp)
+	if err != nil {
+		return nil, p.mapError(err)
+	}
+
+	completion := &Completion{Provider: p.Name(), Model: req.Model}
+	p.merge(completion, &genResp)
+	if err := p.check(completion, &genResp); err != nil {
+		return nil, err
+	}
+	return completion, nil
+}
+
+func (p *geminiProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (*Completion, error) {
+	completion := &Completion{Provider: p.Name(), Model: req.Model}
+
+	// Every event is a partial geminiResponse; the last one carries the
+	// finish reason and final usage.
+	var last geminiResponse
+	err := postStream(ctx, p.client, p.Name(), p.endpoint(req.Model, "streamGenerateContent")+"&alt=sse", nil, toGeminiRequest(req), func(line []byte) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return &ProviderError{Provider: p.Name(), Kind: ErrUnknown, Message: fmt.Sprintf("invalid stream chunk: %v", err)}
+		}
+		if delta := p.merge(completion, &chunk); delta != "" {
+			onDelta(delta)
+		}
+		last = chunk
+		return nil
+	})
+	if err != nil {
+		return nil, p.mapError(err)
+	}
+	if err := p.check(completion, &last); err != nil {
+		return nil, err
+	}
+	return completion, nil
+}
+
+func (p *geminiProvider) endpoint(model, method string) string {
+	return fmt.Sprintf("%s/v1beta/models/%s:%s?key=%s",
+		p.baseURL, url.PathEscape(model), method, url.QueryEscape(p.apiKey))
+}
+
+// merge appends the text of resp's first candidate to completion and copies
+// its usage and model version. It returns the appended text.
+func (p *geminiProvider) merge(completion *Completion, resp *geminiResponse) string {
+	if resp.ModelVersion != "" {
+		completion.Model = resp.ModelVersion
+	}
+	if resp.UsageMetadata.PromptTokenCount != 0 || resp.UsageMetadata.CandidatesTokenCount != 0 {
+		completion.Usage = Usage{
+			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+		}
+	}
+	if len(resp.Candidates) == 0 {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	completion.Content += text.String()
+	return text.String()
+}
+
+// check reports safety blocks and empty answers. Gemini signals both with a
+// missing candidate text, so they'd otherwise look alike.
+func (p *geminiProvider) check(completion *Completion, last *geminiResponse) error {
+	if reason := last.PromptFeedback.BlockReason; reason != "" {
+		return &ProviderError{Provider: p.Name(), Kind: ErrBlocked, Message: "prompt blocked by Gemini safety filter: " + reason}
+	}
+	if completion.Content != "" {
+		return nil
+	}
+	if len(last.Candidates) > 0 {
+		switch reason := last.Candidates[0].FinishReason; reason {
+		case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+			return &ProviderError{Provider: p.Name(), Kind: ErrBlocked, Message: "response blocked by Gemini safety filter: " + reason}
+		}
+	}
+	return &ProviderError{Provider: p.Name(), Kind: ErrEmptyResponse, Message: "no candidates returned"}
+}
+
+// toGeminiRequest converts chat messages to Gemini contents. Gemini calls the
+// assistant role "model" and takes system messages as a separate instruction.
+func toGeminiRequest(req CompletionRequest) geminiRequest {
+	var out geminiRequest
+	var system []geminiPart
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system":
+			system = append(system, geminiPart{Text: msg.Content})
+		case "assistant":
+			out.Contents = append(out.Contents, geminiContent{Role: "model", Parts: []geminiPart{{Text: msg.Content}}})
+		default:
+			out.Contents = append(out.Contents, geminiContent{Role: "user", Parts: []geminiPart{{Text: msg.Content}}})
+		}
+	}
+	if len(system) > 0 {
+		out.SystemInstruction = &geminiContent{Parts: system}
+	}
+	if s := req.Sampling; s.Temperature != nil || s.TopP != nil || s.MaxTokens > 0 || s.Seed != nil || len(s.Stop) > 0 {
+		out.GenerationConfig = &geminiGenerationConfig{
+			Temperature:     s.Temperature,
+			TopP:            s.TopP,
+			MaxOutputTokens: s.MaxTokens,
+			Seed:            s.Seed,
+			StopSequences:   s.Stop,
+		}
+	}
+	return out
+}
+
+// mapError unwraps Gemini's error bodies and keeps the API key, which is
+// part of the request URL, out of transport errors.
+func (p *geminiProvider) mapError(err error) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		uerr.URL = strings.Replace(uerr.URL, url.QueryEscape(p.apiKey), "REDACTED", 1)
+	}
+
+	var perr *ProviderError
+	if !errors.As(err, &perr) {
+		return err
+	}
+	var body geminiErrorResponse
+	if json.Unmarshal([]byte(perr.Message), &body) == nil && body.Error.Message != "" {
+		perr.Message = body.Error.Message
+		if body.Error.Status == "NOT_FOUND" {
+			perr.Kind = ErrModelNotFound
+		}
+	}
+	return perr
+}
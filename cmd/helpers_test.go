@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useTestConfig points the config dir at a temporary directory holding
+// settings, and forgets the limit reservations of earlier tests.
+func useTestConfig(t *testing.T, settings map[string]string) {
+	t.Helper()
+	reservationsMu.Lock()
+	reservations = nil
+	reservationsMu.Unlock()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	if err := os.MkdirAll(getConfigDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range settings {
+		if err := os.WriteFile(filepath.Join(getConfigDir(), name), []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// clearKeyEnv unsets the API key variables so keys resolve from the test
+// config only.
+func clearKeyEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("COMMITER_API_KEY", "")
+	for _, cred := range credentials {
+		for _, name := range cred.env {
+			t.Setenv(name, "")
+		}
+	}
+}
+
+// testRequest is a minimal single-message request.
+func testRequest() CompletionRequest {
+	return CompletionRequest{Model: "qwen2.5-coder", Messages: []Message{{Role: "user", Content: "diff"}}}
+}
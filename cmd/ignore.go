@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultIgnorePatterns exclude files whose diffs are large and say little
+// about a change: lockfiles, vendored dependencies and generated code.
+var defaultIgnorePatterns = []string{
+	"go.sum",
+	"package-lock.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"Cargo.lock",
+	"poetry.lock",
+	"composer.lock",
+	"Gemfile.lock",
+	"vendor/",
+	"node_modules/",
+	"*.pb.go",
+	"*.pb.gw.go",
+	"*_pb2.py",
+	"*_pb2_grpc.py",
+	"*.pb.cc",
+	"*.pb.h",
+	"*.min.js",
+}
+
+// maxAlsoChanged caps how many excluded files are named in the prompt.
+const maxAlsoChanged = 20
+
+// ignoreRule is one gitignore-style pattern.
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool
+}
+
+// parseIgnoreRule compiles a gitignore-style line. It returns false for
+// blank lines and comments.
+//
+// A pattern without a slash matches at any depth, one with a slash is
+// relative to the repo root, a trailing slash matches only directories, "**"
+// spans directories and a leading "!" re-includes what earlier rules
+// excluded.
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	dirOnly := strings.HasSuffix(line, "/")
+	line = strings.TrimSuffix(line, "/")
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	var expr strings.Builder
+	if anchored {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case strings.HasPrefix(line[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			if end := strings.IndexByte(line[i:], ']'); end > 0 {
+				class := line[i+1 : i+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				expr.WriteString("[" + class + "]")
+				i += end
+			} else {
+				expr.WriteString(`\[`)
+			}
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// Diffs only name files, so a directory pattern matches what is inside
+	if dirOnly {
+		expr.WriteString("/.*$")
+	} else {
+		expr.WriteString("(?:/.*)?$")
+	}
+
+	pattern, err := regexp.Compile(expr.String())
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.pattern = pattern
+	return rule, true
+}
+
+// loadIgnoreRules returns the default exclusions followed by the rules in
+// the repo's .commiterignore, so the repo file can override the defaults.
+// Setting default_ignores to false drops the defaults.
+func loadIgnoreRules() []ignoreRule {
+	var lines []string
+	if loadSetting("default_ignores", "true") == "true" {
+		lines = append(lines, defaultIgnorePatterns...)
+	}
+	if repo := currentRepo(); repo != "" {
+		if data, err := os.ReadFile(filepath.Join(repo, ".commiterignore")); err == nil {
+			lines = append(lines, strings.Split(string(data), "\n")...)
+		}
+	}
+
+	var rules []ignoreRule
+	for _, line := range lines {
+		if rule, ok := parseIgnoreRule(line); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ignored reports whether path is excluded by rules. As in gitignore, the
+// last matching rule decides.
+func ignored(path string, rules []ignoreRule) bool {
+	excluded := false
+	for _, rule := range rules {
+		if rule.pattern.MatchString(path) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+// excludeIgnored drops the files matched by the ignore rules from diff and
+// returns what is left along with the names of the dropped files.
+func excludeIgnored(diff string) (string, []string) {
+	rules := loadIgnoreRules()
+	if len(rules) == 0 {
+		return diff, nil
+	}
+
+	var kept strings.Builder
+	var excluded []string
+	for _, file := range parseDiff(diff) {
+		if file.name != "" && ignored(file.name, rules) {
+			excluded = append(excluded, file.name)
+			continue
+		}
+		kept.WriteString(file.header)
+		kept.WriteString(strings.Join(file.hunks, ""))
+	}
+	if len(excluded) == 0 {
+		return diff, nil
+	}
+	return kept.String(), excluded
+}
+
+// alsoChanged names the excluded files for the prompt, or returns "" if
+// there are none.
+func alsoChanged(excluded []string) string {
+	if len(excluded) == 0 {
+		return ""
+	}
+	names := strings.Join(excluded, ", ")
+	if len(excluded) > maxAlsoChanged {
+		names = fmt.Sprintf("%s and %d more", strings.Join(excluded[:maxAlsoChanged], ", "), len(excluded)-maxAlsoChanged)
+	}
+	return fmt.Sprintf("\nalso changed: %s (contents not shown)\n", names)
+}
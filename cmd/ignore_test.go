@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIgnored(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		path  string
+		want  bool
+	}{
+		{"unanchored at root", []string{"go.sum"}, "go.sum", true},
+		{"unanchored at depth", []string{"go.sum"}, "tools/go.sum", true},
+		{"unanchored suffix only", []string{"go.sum"}, "xgo.sum", false},
+		{"anchored at root", []string{"/gen/api.go"}, "gen/api.go", true},
+		{"anchored not at depth", []string{"/gen/api.go"}, "pkg/gen/api.go", false},
+		{"slash inside anchors", []string{"gen/api.go"}, "pkg/gen/api.go", false},
+		{"directory contents", []string{"vendor/"}, "vendor/golang.org/x/net/go.mod", true},
+		{"directory at depth", []string{"vendor/"}, "web/vendor/lib.js", true},
+		{"directory pattern skips file", []string{"vendor/"}, "vendor", false},
+		{"leading double star", []string{"**/testdata"}, "a/b/testdata/x.json", true},
+		{"leading double star at root", []string{"**/testdata"}, "testdata/x.json", true},
+		{"trailing double star", []string{"docs/**"}, "docs/a/b.md", true},
+		{"trailing double star anchored", []string{"docs/**"}, "src/docs/a.md", false},
+		{"star stays in directory", []string{"/*.min.js"}, "dist/app.min.js", false},
+		{"glob at depth", []string{"*.pb.go"}, "api/v1/service.pb.go", true},
+		{"negation overrides default", []string{"go.sum", "!go.sum"}, "go.sum", false},
+		{"negation before rule loses", []string{"!go.sum", "go.sum"}, "go.sum", true},
+		{"negated class excludes", []string{"file[!0-9].txt"}, "file1.txt", false},
+		{"negated class matches", []string{"file[!0-9].txt"}, "filea.txt", true},
+		{"class", []string{"file[ab].txt"}, "fileb.txt", true},
+		{"comment", []string{"# go.sum"}, "go.sum", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []ignoreRule
+			for _, line := range tt.rules {
+				if rule, ok := parseIgnoreRule(line); ok {
+					rules = append(rules, rule)
+				}
+			}
+			if got := ignored(tt.path, rules); got != tt.want {
+				t.Errorf("ignored(%q) with %q = %v, want %v", tt.path, tt.rules, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludeIgnored(t *testing.T) {
+	useTestConfig(t, nil)
+	initTestRepo(t)
+	if err := os.WriteFile(".commiterignore", []byte("docs/\n!go.sum\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diff := testDiff(1, "main.go", "go.sum", "package-lock.json", "docs/guide.md")
+
+	kept, excluded := excludeIgnored(diff)
+	if want := testDiff(1, "main.go", "go.sum"); kept != want {
+		t.Errorf("kept diff =\n%s\nwant\n%s", kept, want)
+	}
+	if len(excluded) != 2 || excluded[0] != "package-lock.json" || excluded[1] != "docs/guide.md" {
+		t.Errorf("excluded = %q, want package-lock.json and docs/guide.md", excluded)
+	}
+	if got, want := alsoChanged(excluded), "\nalso changed: package-lock.json, docs/guide.md (contents not shown)\n"; got != want {
+		t.Errorf("alsoChanged = %q, want %q", got, want)
+	}
+	many := make([]string, maxAlsoChanged+2)
+	for i := range many {
+		many[i] = "x.lock"
+	}
+	if got := alsoChanged(many); !strings.HasSuffix(got, " and 2 more (contents not shown)\n") {
+		t.Errorf("alsoChanged of %d files = %q, want the rest counted", len(many), got)
+	}
+	if got := alsoChanged(nil); got != "" {
+		t.Errorf("alsoChanged(nil) = %q, want empty", got)
+	}
+}
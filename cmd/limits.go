@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// exitLimitExceeded is the exit code of the non-interactive commands when a
+// request is refused by a spending or rate limit.
+const exitLimitExceeded = 3
+
+// limitError is returned for a call refused by one of the limits.
+type limitError struct {
+	reason string
+}
+
+func (e *limitError) Error() string {
+	return "Limit exceeded: " + e.reason
+}
+
+// reservation is a call that passed the limits but isn't in the usage log:
+// it is still running, or it failed within the last minute and so still
+// counts against max_requests_per_minute.
+type reservation struct {
+	time  time.Time
+	calls int
+	cost  float64
+}
+
+// reservationsMu makes checking the limits and reserving a call atomic, so
+// concurrent calls can't all pass before any of them has been recorded.
+var (
+	reservationsMu sync.Mutex
+	reservations   []*reservation
+)
+
+// reserveLimits checks req against the limits and, if it passes or force is
+// set, reserves it until the returned release is called. release is told
+// whether the call's usage was recorded. If a limit would be exceeded and
+// force isn't set, nothing is reserved and the reason is returned.
+func reserveLimits(entry chainEntry, req CompletionRequest, force bool) (release func(recorded bool), reason string) {
+	reservationsMu.Lock()
+	defer reservationsMu.Unlock()
+
+	reason = checkLimits(entry, req)
+	if reason != "" && !force {
+		return nil, reason
+	}
+	r := &reservation{time: time.Now(), calls: max(req.N, 1)}
+	if price, known := priceFor(entry.Provider, entry.Model); known {
+		r.cost = float64(r.calls) * price.cost(estimatePromptTokens(entry, req), reservedOutputTokens)
+	}
+	reservations = append(reservations, r)
+
+	return func(recorded bool) {
+		reservationsMu.Lock()
+		defer reservationsMu.Unlock()
+		if !recorded {
+			r.cost = 0
+			return
+		}
+		for i, other := range reservations {
+			if other == r {
+				reservations = append(reservations[:i], reservations[i+1:]...)
+				break
+			}
+		}
+	}, reason
+}
+
+// pendingCalls returns how many calls and how much estimated cost are
+// reserved but not in the usage log. Failed calls older than a minute are
+// dropped. reservationsMu must be held.
+func pendingCalls() (calls int, cost float64) {
+	cutoff := time.Now().Add(-time.Minute)
+	kept := reservations[:0]
+	for _, r := range reservations {
+		if r.cost == 0 && r.time.Before(cutoff) {
+			continue
+		}
+		kept = append(kept, r)
+		calls += r.calls
+		cost += r.cost
+	}
+	reservations = kept
+	return calls, cost
+}
+
+// estimatePromptTokens estimates how many tokens the messages of req take.
+func estimatePromptTokens(entry chainEntry, req CompletionRequest) int {
+	tokens := 0
+	for _, msg := range req.Messages {
+		tokens += estimateTokens(entry.Model, msg.Content)
+	}
+	return tokens
+}
+
+// checkLimits returns why sending req to entry would exceed one of the
+// max_request_tokens, max_daily_spend or max_requests_per_minute limits, or
+// "" if it wouldn't. Unset limits aren't checked. A request for N candidates
+// counts as N requests, since providers without native support are asked
+// once per candidate. Reserved calls count as sent; reservationsMu must be
+// held.
+func checkLimits(entry chainEntry, req CompletionRequest) string {
+	calls := max(req.N, 1)
+	promptTokens := estimatePromptTokens(entry, req)
+	pending, pendingCost := pendingCalls()
+	if limit := loadIntSetting("max_request_tokens", 0); limit > 0 && promptTokens > limit {
+		return fmt.Sprintf("the request needs about %d tokens, max_request_tokens is %d", promptTokens, limit)
+	}
+
+	if limit := loadIntSetting("max_requests_per_minute", 0); limit > 0 {
+		records, err := loadUsage(time.Now().Add(-time.Minute))
+		if sent := len(records) + pending; err == nil && sent+calls > limit {
+			return fmt.Sprintf("%d requests were sent in the last minute and this needs %d more, max_requests_per_minute is %d", sent, calls, limit)
+		}
+	}
+
+	if limit := loadFloatSetting("max_daily_spend", 0); limit > 0 {
+		price, known := priceFor(entry.Provider, entry.Model)
+		if !known {
+			return fmt.Sprintf("the price of %s is unknown, so max_daily_spend can't be enforced (add it to model_pricing)", entry)
+		}
+		now := time.Now()
+		records, err := loadUsage(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+		if err != nil {
+			return ""
+		}
+		spent := pendingCost
+		for _, record := range records {
+			spent += record.Cost
+		}
+		estimate := float64(calls) * price.cost(promptTokens, reservedOutputTokens)
+		if spent+estimate > limit {
+			return fmt.Sprintf("$%.4f spent today plus about $%.4f for this request exceeds max_daily_spend ($%.2f)", spent, estimate, limit)
+		}
+	}
+	return ""
+}
+
+// limitedProvider checks the limits before every call, retries and extra
+// candidates included, and records the usage of every completion.
+type limitedProvider struct {
+	Provider
+	entry chainEntry
+	// onLimit asks whether to send a call that exceeds a limit anyway.
+	onLimit func(reason string) bool
+}
+
+func withLimits(provider Provider, entry chainEntry, onLimit func(reason string) bool) Provider {
+	return &limitedProvider{Provider: provider, entry: entry, onLimit: onLimit}
+}
+
+func (p *limitedProvider) reserve(req CompletionRequest) (func(recorded bool), error) {
+	release, reason := reserveLimits(p.entry, req, false)
+	if reason == "" {
+		return release, nil
+	}
+	// Don't hold the reservation lock while the user is asked
+	if p.onLimit == nil || !p.onLimit(reason) {
+		return nil, &limitError{reason: reason}
+	}
+	release, _ = reserveLimits(p.entry, req, true)
+	return release, nil
+}
+
+func (p *limitedProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	release, err := p.reserve(req)
+	if err != nil {
+		return nil, err
+	}
+	completion, err := p.Provider.Complete(ctx, req)
+	if err == nil {
+		recordUsage(completion)
+	}
+	release(err == nil)
+	return completion, err
+}
+
+func (p *limitedProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (*Completion, error) {
+	streamer, ok := p.Provider.(StreamingProvider)
+	if !ok {
+		return p.Complete(ctx, req)
+	}
+	release, err := p.reserve(req)
+	if err != nil {
+		return nil, err
+	}
+	completion, err := streamer.Stream(ctx, req, onDelta)
+	if err == nil {
+		recordUsage(completion)
+	}
+	release(err == nil)
+	return completion, err
+}
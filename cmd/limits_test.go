@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newCountingServer serves an openai-compatible endpoint answering with
+// status, or a completion for 200, and counts the requests it gets.
+func newCountingServer(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"fix: typo"}}],"usage":{"prompt_tokens":20,"completion_tokens":3}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestCheckLimitsCountsCandidates(t *testing.T) {
+	useTestConfig(t, map[string]string{
+		"max_requests_per_minute": "2",
+		"max_daily_spend":         "0.001",
+	})
+	entry := chainEntry{Provider: "openrouter", Model: "mistralai/ministral-3b"}
+	req := testRequest()
+
+	req.N = 1
+	if reason := checkLimits(entry, req); reason != "" {
+		t.Errorf("checkLimits for one candidate = %q, want no limit hit", reason)
+	}
+
+	req.N = 3
+	if reason := checkLimits(entry, req); !strings.Contains(reason, "max_requests_per_minute") {
+		t.Errorf("checkLimits for three candidates = %q, want max_requests_per_minute", reason)
+	}
+}
+
+func TestConcurrentCallsCantExceedRateLimit(t *testing.T) {
+	server, hits := newCountingServer(t, http.StatusOK)
+	useTestConfig(t, map[string]string{
+		"provider":                "openai-compatible",
+		"openai_base_url":         server.URL,
+		"max_requests_per_minute": "2",
+	})
+	clearKeyEnv(t)
+
+	refuse := generationHooks{onLimit: func(string) bool { return false }}
+	var wg sync.WaitGroup
+	var refused atomic.Int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := withCacheBypass(context.Background())
+			if result := requestCompletion(ctx, testRequest(), refuse); strings.HasPrefix(result, "Limit exceeded") {
+				refused.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if hits.Load() != 2 || refused.Load() != 6 {
+		t.Errorf("%d requests sent and %d refused, want 2 and 6", hits.Load(), refused.Load())
+	}
+}
+
+func TestRetriesAreCheckedAgainstLimits(t *testing.T) {
+	server, hits := newCountingServer(t, http.StatusInternalServerError)
+	useTestConfig(t, map[string]string{
+		"provider":                "openai-compatible",
+		"openai_base_url":         server.URL,
+		"max_requests_per_minute": "2",
+		"max_attempts":            "5",
+		"retry_base_delay":        "1ms",
+	})
+	clearKeyEnv(t)
+
+	result := requestCompletion(context.Background(), testRequest(), generationHooks{})
+	if !strings.HasPrefix(result, "Limit exceeded") {
+		t.Errorf("result = %q, want the third attempt refused", result)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("%d attempts sent, want 2", hits.Load())
+	}
+}
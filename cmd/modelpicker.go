@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// modelPickerRows is how many models the picker shows at once.
+const modelPickerRows = 12
+
+// modelPicker lets the user filter a model list by typing and save one as
+// the model. Letters go to the filter, so the list moves with the arrows.
+type modelPicker struct {
+	provider string
+	models   []modelInfo
+	matched  []modelInfo
+	filter   string
+	current  string
+	selected int
+	saved    string
+}
+
+func newModelPicker(provider string, models []modelInfo, filter string) modelPicker {
+	m := modelPicker{provider: provider, models: models, filter: filter, current: loadModel()}
+	m.matched = filterModels(models, filter)
+	for i, info := range m.matched {
+		if info.ID == m.current {
+			m.selected = i
+		}
+	}
+	return m
+}
+
+func (m modelPicker) Init() tea.Cmd {
+	return nil
+}
+
+func (m modelPicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch keyMsg.Type {
+	case tea.KeyCtrlC, tea.KeyEsc:
+		return m, tea.Quit
+	case tea.KeyEnter:
+		if len(m.matched) == 0 {
+			return m, nil
+		}
+		m.saved = m.matched[m.selected].ID
+		saveModel(m.saved)
+		return m, tea.Quit
+	case tea.KeyUp:
+		if m.selected > 0 {
+			m.selected--
+		}
+	case tea.KeyDown:
+		if m.selected < len(m.matched)-1 {
+			m.selected++
+		}
+	case tea.KeyBackspace, tea.KeyDelete:
+		if len(m.filter) > 0 {
+			runes := []rune(m.filter)
+			m.setFilter(string(runes[:len(runes)-1]))
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.setFilter(m.filter + string(keyMsg.Runes))
+	}
+	return m, nil
+}
+
+func (m *modelPicker) setFilter(filter string) {
+	m.filter = filter
+	m.matched = filterModels(m.models, filter)
+	m.selected = 0
+}
+
+func (m modelPicker) View() string {
+	if m.saved != "" {
+		return fmt.Sprintf("✅ Model set to %s\n", m.saved)
+	}
+
+	var b strings.Builder
+	title := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("39")).Render("Choose a model (" + m.provider + "):")
+	b.WriteString(title + "\n\n")
+	b.WriteString("Filter: " + m.filter + "█\n\n")
+
+	if len(m.matched) == 0 {
+		b.WriteString(lipgloss.NewStyle().Faint(true).Render("  No models match") + "\n")
+	}
+
+	// Scroll the window so the selection stays visible
+	start := 0
+	if m.selected >= modelPickerRows {
+		start = m.selected - modelPickerRows + 1
+	}
+	end := min(start+modelPickerRows, len(m.matched))
+
+	width := 0
+	for _, info := range m.matched[start:end] {
+		width = max(width, len(info.ID))
+	}
+	selected := lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+	faint := lipgloss.NewStyle().Faint(true)
+	for i := start; i < end; i++ {
+		info := m.matched[i]
+		cursor := "  "
+		if i == m.selected {
+			cursor = "> "
+		}
+		marker := " "
+		if info.ID == m.current {
+			marker = "*"
+		}
+		line := fmt.Sprintf("%s%s %-*s  %6s  %s", cursor, marker, width, info.ID, info.contextLabel(), info.priceLabel())
+		if i == m.selected {
+			line = selected.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(m.matched) > modelPickerRows {
+		b.WriteString(faint.Render(fmt.Sprintf("  %d of %d models", end-start, len(m.matched))) + "\n")
+	}
+
+	b.WriteString("\nType to filter | ↑/↓ - Select | Enter - Save | Esc - Quit")
+	return b.String()
+}
+
+func runModelPicker(provider string, models []modelInfo, filter string) error {
+	p := tea.NewProgram(newModelPicker(provider, models, filter))
+	_, err := p.Run()
+	return err
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	openRouterModelsURL = "https://openrouter.ai/api/v1/models"
+	// defaultModelsTTL is how long a fetched model list is used before it
+	// is fetched again.
+	defaultModelsTTL = 24 * time.Hour
+)
+
+// modelInfo describes a model a provider offers. Prices are in USD per
+// million tokens and nil when the provider does not publish them.
+type modelInfo struct {
+	ID              string   `json:"id"`
+	ContextLength   int      `json:"context_length,omitempty"`
+	PromptPrice     *float64 `json:"prompt_price,omitempty"`
+	CompletionPrice *float64 `json:"completion_price,omitempty"`
+}
+
+// modelList is a provider's model list as cached on disk.
+type modelList struct {
+	Fetched time.Time   `json:"fetched"`
+	Models  []modelInfo `json:"models"`
+}
+
+// openRouterModels is the response of OpenRouter's model list. Prices are
+// strings in USD per token.
+type openRouterModels struct {
+	Data []struct {
+		ID            string `json:"id"`
+		ContextLength int    `json:"context_length"`
+		Pricing       struct {
+			Prompt     string `json:"prompt"`
+			Completion string `json:"completion"`
+		} `json:"pricing"`
+	} `json:"data"`
+}
+
+// ollamaTags is the response of Ollama's list of pulled models.
+type ollamaTags struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+func getModelsDir() string {
+	return filepath.Join(getConfigDir(), "models")
+}
+
+// fetchModels asks provider for the models it offers, sorted by ID.
+func fetchModels(ctx context.Context, provider string) ([]modelInfo, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var models []modelInfo
+	switch provider {
+	case "openrouter":
+		var resp openRouterModels
+		if err := getJSON(ctx, client, provider, openRouterModelsURL, nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, m := range resp.Data {
+			models = append(models, modelInfo{
+				ID:              m.ID,
+				ContextLength:   m.ContextLength,
+				PromptPrice:     perMillion(m.Pricing.Prompt),
+				CompletionPrice: perMillion(m.Pricing.Completion),
+			})
+		}
+	case "ollama":
+		baseURL := strings.TrimRight(loadSetting("ollama_url", defaultOllamaURL), "/")
+		var resp ollamaTags
+		if err := getJSON(ctx, client, provider, baseURL+"/api/tags", nil, &resp); err != nil {
+			return nil, (&ollamaProvider{baseURL: baseURL}).mapError(err, "")
+		}
+		free := 0.0
+		for _, m := range resp.Models {
+			models = append(models, modelInfo{ID: m.Name, PromptPrice: &free, CompletionPrice: &free})
+		}
+	default:
+		return nil, fmt.Errorf("listing models is not supported for %s", provider)
+	}
+
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models, nil
+}
+
+// perMillion converts a per-token price string to USD per million tokens.
+func perMillion(price string) *float64 {
+	value, err := strconv.ParseFloat(price, 64)
+	if err != nil || value < 0 {
+		return nil
+	}
+	value *= 1e6
+	return &value
+}
+
+// loadModels returns provider's model list, fetching it when the cached one
+// is older than models_ttl or refresh is set. If fetching fails the cached
+// list is returned with a notice, so the list stays usable offline.
+func loadModels(ctx context.Context, provider string, refresh bool) ([]modelInfo, string, error) {
+	path := filepath.Join(getModelsDir(), provider+".json")
+	var cached modelList
+	haveCache := false
+	if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &cached) == nil {
+		haveCache = true
+	}
+
+	ttl := loadDurationSetting("models_ttl", defaultModelsTTL)
+	if haveCache && !refresh && time.Since(cached.Fetched) < ttl {
+		return cached.Models, "", nil
+	}
+
+	models, err := fetchModels(ctx, provider)
+	if err != nil {
+		if haveCache {
+			notice := fmt.Sprintf("Could not fetch models (%s); showing the list from %s", describeError(err), cached.Fetched.Format("2006-01-02"))
+			return cached.Models, notice, nil
+		}
+		return nil, "", err
+	}
+
+	if data, err := json.Marshal(modelList{Fetched: time.Now(), Models: models}); err == nil {
+		os.MkdirAll(getModelsDir(), 0755)
+		os.WriteFile(path, data, 0644)
+	}
+	return models, "", nil
+}
+
+// filterModels returns the models whose ID contains every word of filter,
+// ignoring case.
+func filterModels(models []modelInfo, filter string) []modelInfo {
+	words := strings.Fields(strings.ToLower(filter))
+	var matched []modelInfo
+	for _, m := range models {
+		id := strings.ToLower(m.ID)
+		ok := true
+		for _, word := range words {
+			if !strings.Contains(id, word) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, m)
+		}
+	}
+	return matched
+}
+
+// contextLabel and priceLabel format a model's details for listings.
+func (m modelInfo) contextLabel() string {
+	if m.ContextLength == 0 {
+		return "-"
+	}
+	if m.ContextLength >= 1000 {
+		return fmt.Sprintf("%dk", m.ContextLength/1000)
+	}
+	return strconv.Itoa(m.ContextLength)
+}
+
+func (m modelInfo) priceLabel() string {
+	if m.PromptPrice == nil || m.CompletionPrice == nil {
+		return "-"
+	}
+	if *m.PromptPrice == 0 && *m.CompletionPrice == 0 {
+		return "free"
+	}
+	return fmt.Sprintf("$%.2f / $%.2f", *m.PromptPrice, *m.CompletionPrice)
+}
+
+// writeModelsTable lists models with their context length and price per
+// million prompt and completion tokens.
+func writeModelsTable(w io.Writer, models []modelInfo) {
+	current := loadModel()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tMODEL\tCONTEXT\tPRICE (IN / OUT PER 1M)")
+	for _, m := range models {
+		marker := ""
+		if m.ID == current {
+			marker = "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", marker, m.ID, m.contextLabel(), m.priceLabel())
+	}
+	tw.Flush()
+}
+
+// runModels lists the selected provider's models matching filter, or lets
+// the user pick one in the TUI and saves it as the model.
+func runModels(ctx context.Context, filter string, list, refresh bool) error {
+	provider := loadProviderName()
+	models, notice, err := loadModels(ctx, provider, refresh)
+	if err != nil {
+		return fmt.Errorf("fetching models: %s", describeError(err))
+	}
+	if notice != "" {
+		fmt.Fprintln(os.Stderr, notice)
+	}
+
+	if list {
+		matched := filterModels(models, filter)
+		if len(matched) == 0 {
+			fmt.Printf("No %s models match %q.\n", provider, filter)
+			return nil
+		}
+		writeModelsTable(os.Stdout, matched)
+		return nil
+	}
+	return runModelPicker(provider, models, filter)
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultOllamaURL = "http://localhost:11434"
+
+type ollamaChatRequest struct {
+	Model    string         `json:"model"`
+	Messages []Message      `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Format   map[string]any `json:"format,omitempty"`
+	Options  *ollamaOptions `json:"options,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Model           string  `json:"model"`
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+	Error           string  `json:"error"`
+}
+
+type ollamaErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// ollamaProvider talks to a local Ollama server, so diffs never leave the
+// machine.
+type ollamaProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newOllamaProvider(baseURL string, client *http.Client) *ollamaProvider {
+	return &ollamaProvider{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+func (p *ollamaProvider) Name() string {
+	return "ollama"
+}
+
+func (p *ollamaProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	reqBody := newOllamaRequest(req, false)
+
+	var chatResp ollamaChatResponse
+	err := postJSON(ctx, p.client, p.Name(), p.baseURL+"/api/chat", nil, reqBody, &chatResp)
+	if err != nil {
+		return nil, p.mapError(err, req.Model)
+	}
+	if chatResp.Message.Content == "" {
+		return nil, &ProviderError{Provider: p.Name(), Kind: ErrEmptyResponse, Message: "empty message returned"}
+	}
+
+	completion := &Completion{
+		Content:  chatResp.Message.Content,
+		Provider: p.Name(),
+		Model:    req.Model,
+		Usage: Usage{
+			PromptTokens:     chatResp.PromptEvalCount,
+			CompletionTokens: chatResp.EvalCount,
+		},
+	}
+	if chatResp.Model != "" {
+		completion.Model = chatResp.Model
+	}
+	return completion, nil
+}
+
+func (p *ollamaProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (*Completion, error) {
+	reqBody := newOllamaRequest(req, true)
+	completion := &Completion{Provider: p.Name(), Model: req.Model}
+
+	// Ollama streams newline-delimited JSON objects rather than SSE.
+	var content strings.Builder
+	err := postStream(ctx, p.client, p.Name(), p.baseURL+"/api/chat", nil, reqBody, func(line []byte) error {
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return &ProviderError{Provider: p.Name(), Kind: ErrUnknown, Message: fmt.Sprintf("invalid stream chunk: %v", err)}
+		}
+		if chunk.Error != "" {
+			return &ProviderError{Provider: p.Name(), Kind: ErrServer, Message: chunk.Error}
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			onDelta(chunk.Message.Content)
+		}
+		if chunk.Done {
+			if chunk.Model != "" {
+				completion.Model = chunk.Model
+			}
+			completion.Usage = Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, p.mapError(err, req.Model)
+	}
+	if content.Len() == 0 {
+		return nil, &ProviderError{Provider: p.Name(), Kind: ErrEmptyResponse, Message: "empty message returned"}
+	}
+	completion.Content = content.String()
+	return completion, nil
+}
+
+func newOllamaRequest(req CompletionRequest, stream bool) ollamaChatRequest {
+	reqBody := ollamaChatRequest{Model: req.Model, Messages: req.Messages, Stream: stream}
+	if req.Schema != nil {
+		reqBody.Format = req.Schema.Schema
+	}
+	if s := req.Sampling; s.Temperature != nil || s.TopP != nil || s.MaxTokens > 0 || s.Seed != nil || len(s.Stop) > 0 {
+		reqBody.Options = &ollamaOptions{
+			Temperature: s.Temperature,
+			TopP:        s.TopP,
+			NumPredict:  s.MaxTokens,
+			Seed:        s.Seed,
+			Stop:        s.Stop,
+		}
+	}
+	return reqBody
+}
+
+// mapError unwraps Ollama's {"error": "..."} bodies and points the user at
+// `ollama pull` when the model hasn't been downloaded yet.
+func (p *ollamaProvider) mapError(err error, model string) error {
+	var perr *ProviderError
+	if !errors.As(err, &perr) {
+		return fmt.Errorf("%w (is Ollama running at %s?)", err, p.baseURL)
+	}
+
+	var body ollamaErrorResponse
+	if json.Unmarshal([]byte(perr.Message), &body) == nil && body.Error != "" {
+		perr.Message = body.Error
+	}
+	if perr.StatusCode == http.StatusNotFound || strings.Contains(perr.Message, "try pulling it first") {
+		perr.Kind = ErrModelNotFound
+		perr.Message = fmt.Sprintf("%s (run `ollama pull %s`)", perr.Message, model)
+	}
+	return perr
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newOllamaTestServer(t *testing.T, handler http.HandlerFunc) *ollamaProvider {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return newOllamaProvider(server.URL, server.Client())
+}
+
+func TestOllamaComplete(t *testing.T) {
+	provider := newOllamaTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %s, want /api/chat", r.URL.Path)
+		}
+		var req ollamaChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+			return
+		}
+		if req.Stream || req.Model != "qwen2.5-coder" {
+			t.Errorf("request = %+v, want a non-streaming request for qwen2.5-coder", req)
+		}
+		w.Write([]byte(`{"model":"qwen2.5-coder","message":{"role":"assistant","content":"feat: add parser"},"done":true,"prompt_eval_count":12,"eval_count":4}`))
+	})
+
+	completion, err := provider.Complete(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if completion.Content != "feat: add parser" {
+		t.Errorf("Content = %q, want %q", completion.Content, "feat: add parser")
+	}
+	if completion.Usage.PromptTokens != 12 || completion.Usage.CompletionTokens != 4 {
+		t.Errorf("Usage = %+v, want 12 prompt and 4 completion tokens", completion.Usage)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	provider := newOllamaTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":{"content":"feat: "},"done":false}` + "\n"))
+		w.Write([]byte(`{"message":{"content":"add parser"},"done":false}` + "\n"))
+		w.Write([]byte(`{"model":"qwen2.5-coder","message":{"content":""},"done":true,"prompt_eval_count":12,"eval_count":4}` + "\n"))
+	})
+
+	var deltas []string
+	completion, err := provider.Stream(context.Background(), testRequest(), func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if strings.Join(deltas, "|") != "feat: |add parser" {
+		t.Errorf("deltas = %q, want the two chunks in order", deltas)
+	}
+	if completion.Content != "feat: add parser" {
+		t.Errorf("Content = %q, want %q", completion.Content, "feat: add parser")
+	}
+	if completion.Usage.CompletionTokens != 4 {
+		t.Errorf("CompletionTokens = %d, want 4", completion.Usage.CompletionTokens)
+	}
+}
+
+func TestOllamaModelNotFound(t *testing.T) {
+	provider := newOllamaTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"model \"qwen2.5-coder\" not found, try pulling it first"}`))
+	})
+
+	_, err := provider.Complete(context.Background(), testRequest())
+	var perr *ProviderError
+	if !errors.As(err, &perr) {
+		t.Fatalf("err = %v, want a *ProviderError", err)
+	}
+	if perr.Kind != ErrModelNotFound {
+		t.Errorf("Kind = %v, want ErrModelNotFound", perr.Kind)
+	}
+	if !strings.Contains(perr.Message, "ollama pull qwen2.5-coder") {
+		t.Errorf("Message = %q, want the ollama pull hint", perr.Message)
+	}
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// openAIProvider talks to any server exposing the OpenAI /chat/completions
+// dialect: llama.cpp, vLLM, LM Studio, corporate gateways and OpenRouter.
+type openAIProvider struct {
+	name       string
+	url        string
+	authHeader string
+	apiKey     string
+	headers    map[string]string
+	client     *http.Client
+	// includeUsage requests OpenRouter's usage accounting, which adds the
+	// cost of the call to the response.
+	includeUsage bool
+}
+
+func newOpenAIProvider(name, url, authHeader, apiKey string, headers map[string]string, client *http.Client) *openAIProvider {
+	return &openAIProvider{
+		name:       name,
+		url:        url,
+		authHeader: authHeader,
+		apiKey:     apiKey,
+		headers:    headers,
+		client:     client,
+	}
+}
+
+// newOpenAICompatibleProvider builds the "openai-compatible" provider from
+// the openai_* settings.
+func newOpenAICompatibleProvider(apiKey string, client *http.Client) *openAIProvider {
+	baseURL := strings.TrimRight(loadSetting("openai_base_url", "http://localhost:8080/v1"), "/")
+	return newOpenAIProvider(
+		"openai-compatible",
+		baseURL+"/chat/completions",
+		loadSetting("openai_auth_header", "Authorization"),
+		apiKey,
+		loadHeadersSetting("openai_headers"),
+		client,
+	)
+}
+
+func (p *openAIProvider) Name() string {
+	return p.name
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	reqBody := p.newRequest(req, false)
+
+	var orResp OpenRouterResponse
+	if err := postJSON(ctx, p.client, p.Name(), p.url, p.requestHeaders(), reqBody, &orResp); err != nil {
+		return nil, err
+	}
+	if len(orResp.Choices) == 0 {
+		return nil, &ProviderError{Provider: p.Name(), Kind: ErrEmptyResponse, Message: "no choices returned"}
+	}
+	if choice := orResp.Choices[0]; choice.Message.Content == "" && choice.FinishReason == "content_filter" {
+		return nil, &ProviderError{Provider: p.Name(), Kind: ErrBlocked, Message: "response blocked by content filter"}
+	}
+
+	completion := &Completion{
+		Content:  orResp.Choices[0].Message.Content,
+		Provider: p.Name(),
+		Model:    req.Model,
+	}
+	if len(orResp.Choices) > 1 {
+		for _, choice := range orResp.Choices {
+			if choice.Message.Content != "" {
+				completion.Choices = append(completion.Choices, choice.Message.Content)
+			}
+		}
+	}
+	if orResp.Model != "" {
+		completion.Model = orResp.Model
+	}
+	if orResp.Usage != nil {
+		completion.Usage = orResp.Usage.toUsage()
+	}
+	return completion, nil
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (*Completion, error) {
+	reqBody := p.newRequest(req, true)
+	completion := &Completion{Provider: p.Name(), Model: req.Model}
+
+	// With n > 1 the choices arrive interleaved; only the first is streamed
+	// to onDelta, the others are collected by index
+	var content []strings.Builder
+	var finishReason string
+	err := postStream(ctx, p.client, p.Name(), p.url, p.requestHeaders(), reqBody, func(line []byte) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var chunk OpenRouterStreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return &ProviderError{Provider: p.Name(), Kind: ErrUnknown, Message: fmt.Sprintf("invalid stream chunk: %v", err)}
+		}
+		if chunk.Error != nil {
+			return &ProviderError{Provider: p.Name(), Kind: ErrServer, Message: chunk.Error.Message}
+		}
+		if chunk.Model != "" {
+			completion.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			completion.Usage = chunk.Usage.toUsage()
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index < 0 || choice.Index >= max(req.N, 1) {
+				continue
+			}
+			for len(content) <= choice.Index {
+				content = append(content, strings.Builder{})
+			}
+			if delta := choice.Delta.Content; delta != "" {
+				content[choice.Index].WriteString(delta)
+				if choice.Index == 0 {
+					onDelta(delta)
+				}
+			}
+			if choice.Index == 0 && choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) == 0 || content[0].Len() == 0 {
+		if finishReason == "content_filter" {
+			return nil, &ProviderError{Provider: p.Name(), Kind: ErrBlocked, Message: "response blocked by content filter"}
+		}
+		return nil, &ProviderError{Provider: p.Name(), Kind: ErrEmptyResponse, Message: "empty stream returned"}
+	}
+	completion.Content = content[0].String()
+	if len(content) > 1 {
+		for i := range content {
+			if content[i].Len() > 0 {
+				completion.Choices = append(completion.Choices, content[i].String())
+			}
+		}
+	}
+	return completion, nil
+}
+
+func (p *openAIProvider) newRequest(req CompletionRequest, stream bool) OpenRouterRequest {
+	reqBody := OpenRouterRequest{
+		Model:       req.Model,
+		Messages:    req.Messages,
+		Stream:      stream,
+		Temperature: req.Sampling.Temperature,
+		TopP:        req.Sampling.TopP,
+		MaxTokens:   req.Sampling.MaxTokens,
+		Seed:        req.Sampling.Seed,
+		Stop:        req.Sampling.Stop,
+	}
+	if req.N > 1 {
+		reqBody.N = req.N
+	}
+	if req.Schema != nil {
+		reqBody.ResponseFormat = &ResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &ResponseFormatSchema{Name: req.Schema.Name, Strict: true, Schema: req.Schema.Schema},
+		}
+	}
+	// OpenRouter reports usage through its own option; other servers only
+	// send usage in a stream when asked to
+	if p.includeUsage {
+		reqBody.Usage = &OpenRouterUsageOpt{Include: true}
+	} else if stream {
+		reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	return reqBody
+}
+
+// requestHeaders returns the extra headers plus the auth header. The key is
+// sent as a bearer token in Authorization and verbatim in any other header.
+func (p *openAIProvider) requestHeaders() map[string]string {
+	headers := make(map[string]string, len(p.headers)+1)
+	for name, value := range p.headers {
+		headers[name] = value
+	}
+	if p.apiKey != "" && p.authHeader != "" {
+		if strings.EqualFold(p.authHeader, "Authorization") {
+			headers[p.authHeader] = "Bearer " + p.apiKey
+		} else {
+			headers[p.authHeader] = p.apiKey
+		}
+	}
+	return headers
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIStreamRequestsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OpenRouterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+			return
+		}
+		if req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("stream_options = %+v, want include_usage", req.StreamOptions)
+		}
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"fix: typo\"}}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[],\"usage\":{\"prompt_tokens\":20,\"completion_tokens\":3}}\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	provider := newOpenAIProvider("openai-compatible", server.URL, "Authorization", "", nil, server.Client())
+	completion, err := provider.Stream(context.Background(), testRequest(), func(string) {})
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if completion.Content != "fix: typo" {
+		t.Errorf("Content = %q, want %q", completion.Content, "fix: typo")
+	}
+	if completion.Usage.PromptTokens != 20 || completion.Usage.CompletionTokens != 3 {
+		t.Errorf("Usage = %+v, want 20 prompt and 3 completion tokens", completion.Usage)
+	}
+}
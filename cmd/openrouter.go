@@ -0,0 +1,98 @@
+package cmd
+
+import "net/http"
+
+const openRouterURL = "https://openrouter.ai/api/v1/chat/completions"
+
+type OpenRouterRequest struct {
+	Model    string              `json:"model"`
+	Messages []Message           `json:"messages"`
+	Stream   bool                `json:"stream,omitempty"`
+	N        int                 `json:"n,omitempty"`
+	Usage    *OpenRouterUsageOpt `json:"usage,omitempty"`
+	// StreamOptions asks OpenAI-style servers for a final usage chunk
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat requests structured output matching a JSON schema.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type ResponseFormat struct {
+	Type       string                `json:"type"`
+	JSONSchema *ResponseFormatSchema `json:"json_schema,omitempty"`
+}
+
+type ResponseFormatSchema struct {
+	Name   string         `json:"name"`
+	Strict bool           `json:"strict"`
+	Schema map[string]any `json:"schema"`
+}
+
+// OpenRouterUsageOpt asks OpenRouter to include token counts and cost in the
+// response.
+type OpenRouterUsageOpt struct {
+	Include bool `json:"include"`
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type OpenRouterResponse struct {
+	Model   string           `json:"model"`
+	Choices []Choice         `json:"choices"`
+	Usage   *OpenRouterUsage `json:"usage"`
+}
+
+type Choice struct {
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// OpenRouterStreamChunk is one server-sent event of a streamed completion.
+// Errors raised after the stream has started arrive in the Error field.
+type OpenRouterStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int     `json:"index"`
+		Delta        Message `json:"delta"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *OpenRouterUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type OpenRouterUsage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (u *OpenRouterUsage) toUsage() Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		Cost:             u.Cost,
+	}
+}
+
+// newOpenRouterProvider returns a provider for the OpenRouter chat
+// completions API, which speaks the OpenAI dialect.
+func newOpenRouterProvider(apiKey string, client *http.Client) *openAIProvider {
+	provider := newOpenAIProvider("openrouter", openRouterURL, "Authorization", apiKey, nil, client)
+	provider.includeUsage = true
+	return provider
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+)
+
+// modelPrice is the price of a model in USD per million tokens.
+type modelPrice struct {
+	prompt     float64
+	completion float64
+}
+
+// modelPrices maps model name fragments to prices. Like contextWindows, the
+// first fragment contained in the model name wins.
+var modelPrices = []struct {
+	fragment string
+	price    modelPrice
+}{
+	{"ministral-3b", modelPrice{0.04, 0.04}},
+	{"ministral-8b", modelPrice{0.10, 0.10}},
+	{"mistral-small", modelPrice{0.20, 0.60}},
+	{"mistral-large", modelPrice{2, 6}},
+	{"gpt-4o-mini", modelPrice{0.15, 0.60}},
+	{"gpt-4o", modelPrice{2.50, 10}},
+	{"gpt-4.1-nano", modelPrice{0.10, 0.40}},
+	{"gpt-4.1-mini", modelPrice{0.40, 1.60}},
+	{"gpt-4.1", modelPrice{2, 8}},
+	{"claude-3-5-haiku", modelPrice{0.80, 4}},
+	{"claude-3-haiku", modelPrice{0.25, 1.25}},
+	{"haiku", modelPrice{1, 5}},
+	{"sonnet", modelPrice{3, 15}},
+	{"opus", modelPrice{15, 75}},
+	{"gemini-1.5-flash", modelPrice{0.075, 0.30}},
+	{"gemini-2.0-flash", modelPrice{0.10, 0.40}},
+	{"gemini-1.5-pro", modelPrice{1.25, 5}},
+}
+
+// priceFor returns the price of model on provider. The model_pricing setting,
+// one "model prompt completion" line per model, overrides the built-in table.
+// Models served by Ollama are local and free.
+func priceFor(provider, model string) (modelPrice, bool) {
+	for _, line := range strings.Split(loadSetting("model_pricing", ""), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != model {
+			continue
+		}
+		prompt, err1 := strconv.ParseFloat(fields[1], 64)
+		completion, err2 := strconv.ParseFloat(fields[2], 64)
+		if err1 == nil && err2 == nil {
+			return modelPrice{prompt, completion}, true
+		}
+	}
+	if provider == "ollama" {
+		return modelPrice{}, true
+	}
+	name := strings.ToLower(model)
+	for _, entry := range modelPrices {
+		if strings.Contains(name, entry.fragment) {
+			return entry.price, true
+		}
+	}
+	return modelPrice{}, false
+}
+
+// cost returns the price of the given token counts in USD.
+func (p modelPrice) cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.prompt + float64(completionTokens)*p.completion) / 1e6
+}
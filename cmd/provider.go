@@ -0,0 +1,460 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Provider sends prompts to an LLM backend.
+type Provider interface {
+	Name() string
+	Complete(ctx context.Context, req CompletionRequest) (*Completion, error)
+}
+
+// StreamingProvider is implemented by providers that can deliver a
+// completion incrementally. onDelta is called with each new piece of text;
+// the returned Completion holds the full content.
+type StreamingProvider interface {
+	Provider
+	Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (*Completion, error)
+}
+
+// CompletionRequest is a backend-agnostic chat completion request. N asks
+// for that many candidates; providers that can't return several in one call
+// return one. Schema asks for JSON output matching it, which providers
+// without a JSON mode ignore.
+type CompletionRequest struct {
+	Model    string
+	Messages []Message
+	N        int
+	Schema   *ResponseSchema
+	Sampling Sampling
+}
+
+// ResponseSchema is a named JSON schema for structured output.
+type ResponseSchema struct {
+	Name   string
+	Schema map[string]any
+}
+
+// Completion is the result of a successful provider call. When several
+// candidates were requested, Choices holds all of them and Content the first.
+type Completion struct {
+	Content  string
+	Choices  []string
+	Provider string
+	Model    string
+	Usage    Usage
+	Cached   bool
+}
+
+// candidates returns every candidate of the completion.
+func (c *Completion) candidates() []string {
+	if len(c.Choices) > 0 {
+		return c.Choices
+	}
+	return []string{c.Content}
+}
+
+// Usage reports the tokens consumed by a single call and, where the provider
+// reports it, its cost in USD.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+// ErrorKind classifies provider failures so callers can react to them.
+type ErrorKind int
+
+const (
+	ErrUnknown ErrorKind = iota
+	ErrAuth
+	ErrBadRequest
+	ErrRateLimited
+	ErrServer
+	ErrEmptyResponse
+	ErrModelNotFound
+	ErrBlocked
+)
+
+// ProviderError is returned by providers when the backend rejects a request
+// or answers with something that cannot be used as a completion.
+type ProviderError struct {
+	Provider   string
+	Kind       ErrorKind
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: status %d: %s", e.Provider, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Provider, e.Message)
+}
+
+// kindForStatus maps an HTTP status code to an ErrorKind.
+func kindForStatus(status int) ErrorKind {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status >= 500:
+		return ErrServer
+	case status >= 400:
+		return ErrBadRequest
+	default:
+		return ErrUnknown
+	}
+}
+
+const (
+	defaultTimeout           = 2 * time.Minute
+	defaultGenerationTimeout = 5 * time.Minute
+	defaultConnectTimeout    = 10 * time.Second
+)
+
+// errGenerationTimeout is the cause of a generation cancelled by
+// generation_timeout.
+var errGenerationTimeout = errors.New("generation timed out")
+
+// withGenerationTimeout bounds a whole generation, fallbacks and summaries
+// included, by the generation_timeout setting. "timeout" bounds each
+// provider call within it.
+func withGenerationTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, loadDurationSetting("generation_timeout", defaultGenerationTimeout), errGenerationTimeout)
+}
+
+// describeError turns a provider error into the message shown to the user.
+// The prefixes match the ones the non-interactive commands check for.
+func describeError(err error) string {
+	if errors.Is(err, errGenerationTimeout) {
+		return fmt.Sprintf("Error: generation timed out after %s", loadDurationSetting("generation_timeout", defaultGenerationTimeout))
+	}
+	if errors.Is(err, context.Canceled) {
+		return "Error: generation cancelled"
+	}
+	var lerr *limitError
+	if errors.As(err, &lerr) {
+		return lerr.Error()
+	}
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		if perr.Kind == ErrEmptyResponse {
+			return "No response from API"
+		}
+		return fmt.Sprintf("API error: %s", perr.Message)
+	}
+	return fmt.Sprintf("Error making request: %v", err)
+}
+
+// postJSON sends body as JSON to url and decodes a successful response into
+// out. Non-2xx responses are returned as a *ProviderError holding the raw body.
+func postJSON(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, body, out any) error {
+	resp, err := sendJSON(ctx, client, provider, url, headers, body)
+	if err != nil {
+		return err
+	}
+	return decodeJSON(provider, resp, out)
+}
+
+// getJSON fetches url and decodes a successful response into out. Errors are
+// reported like postJSON.
+func getJSON(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := doRequest(client, provider, req)
+	if err != nil {
+		return err
+	}
+	return decodeJSON(provider, resp, out)
+}
+
+// decodeJSON reads and closes resp, decoding its body into out.
+func decodeJSON(provider string, resp *http.Response, out any) error {
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return &ProviderError{Provider: provider, Kind: ErrUnknown, Message: fmt.Sprintf("invalid response: %v", err)}
+	}
+	return nil
+}
+
+// postStream sends body as JSON to url and calls onLine with every non-empty
+// line of the response as it arrives. Errors are reported like postJSON.
+func postStream(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, body any, onLine func(line []byte) error) error {
+	resp, err := sendJSON(ctx, client, provider, url, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := onLine(line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// sseData returns the payload of a server-sent event "data:" line. Comments,
+// event names and the OpenAI "[DONE]" sentinel are skipped.
+func sseData(line []byte) ([]byte, bool) {
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	if !ok {
+		return nil, false
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("[DONE]")) {
+		return nil, false
+	}
+	return data, true
+}
+
+// sendJSON posts body as JSON and returns the response as doRequest does.
+func sendJSON(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, body any) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return doRequest(client, provider, req)
+}
+
+// doRequest sends req and returns the response if its status is 2xx.
+// Otherwise the body is read into a *ProviderError and the response closed.
+func doRequest(client *http.Client, provider string, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &ProviderError{
+		Provider:   provider,
+		Kind:       kindForStatus(resp.StatusCode),
+		StatusCode: resp.StatusCode,
+		Message:    string(respBody),
+		RetryAfter: retryAfter(resp.Header),
+	}
+}
+
+// newProvider builds the provider registered under name for model.
+func newProvider(name, model string) (Provider, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "openrouter":
+		apiKey, err := loadAPIKey()
+		if err != nil {
+			return nil, err
+		}
+		return newOpenRouterProvider(apiKey, client), nil
+	case "ollama":
+		return newOllamaProvider(loadSetting("ollama_url", defaultOllamaURL), client), nil
+	case "openai-compatible":
+		// Local servers usually need no key, so a missing one is not an
+		// error, but a failing key command is
+		apiKey, err := loadProviderKey(name)
+		if err != nil && !errors.Is(err, errNoAPIKey) {
+			return nil, err
+		}
+		return newOpenAICompatibleProvider(apiKey, client), nil
+	case "anthropic":
+		apiKey, err := loadProviderKey(name)
+		if err != nil {
+			return nil, err
+		}
+		return newAnthropicProvider(loadSetting("anthropic_url", defaultAnthropicURL), apiKey, client), nil
+	case "gemini":
+		apiKey, err := loadProviderKey(name)
+		if err != nil {
+			return nil, err
+		}
+		return newGeminiProvider(loadSetting("gemini_url", defaultGeminiURL), apiKey, client), nil
+	case "replay":
+		return newReplayProvider(getCassetteDir()), nil
+	case "azure":
+		resource := loadSetting("azure_resource", "")
+		if resource == "" {
+			return nil, fmt.Errorf("azure_resource is not set")
+		}
+		apiKey, err := loadProviderKey(name)
+		if err != nil {
+			return nil, err
+		}
+		deployment := loadSetting("azure_deployment", model)
+		apiVersion := loadSetting("azure_api_version", defaultAzureAPIVersion)
+		return newAzureProvider(resource, deployment, apiVersion, apiKey, client), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q", name)
+	}
+}
+
+// generationHooks receives progress from a running generation. Nil hooks
+// are skipped; a nil onDelta also disables streaming.
+type generationHooks struct {
+	onDelta    func(string)
+	onRetry    func(attempt, max int)
+	onNotice   func(text string)
+	onProgress func(progress summaryProgress)
+	onFallback func(entry chainEntry)
+	onAnswer   func(completion *Completion)
+	// onLimit asks whether to send a request that exceeds a spending or
+	// rate limit. Without it such requests are refused.
+	onLimit func(reason string) bool
+}
+
+// requestCompletion sends req to each entry of the fallback chain in turn
+// and returns the first completion text, or a user-facing error message.
+func requestCompletion(ctx context.Context, req CompletionRequest, hooks generationHooks) string {
+	completion, failure := completeChain(ctx, req, hooks)
+	if completion == nil {
+		return failure
+	}
+	return completion.Content
+}
+
+// promptRequest returns a request sending prompt as a single user message,
+// with the sampling parameters configured for action.
+func promptRequest(prompt, action string) CompletionRequest {
+	return CompletionRequest{
+		Messages: []Message{{Role: "user", Content: prompt}},
+		Sampling: loadSampling(action),
+	}
+}
+
+// completeChain is requestCompletion for callers that need to tell failures
+// apart from completions. The model and number of
+// candidates are filled in for each entry. Exactly one result is set.
+func completeChain(ctx context.Context, req CompletionRequest, hooks generationHooks) (*Completion, string) {
+	chain := loadFallbackChain()
+
+	var failure string
+	for i, entry := range chain {
+		if i > 0 && hooks.onFallback != nil {
+			hooks.onFallback(entry)
+		}
+		completion, message := completeWith(ctx, entry, req, hooks)
+		if completion != nil {
+			if hooks.onAnswer != nil {
+				hooks.onAnswer(completion)
+			}
+			return completion, ""
+		}
+		failure = message
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(chain) > 1 {
+		failure += fmt.Sprintf(" (all %d entries of fallback_chain failed)", len(chain))
+	}
+	return nil, failure
+}
+
+// completeWith asks a single chain entry for a completion. The call, retries
+// included, is bounded by the "timeout" setting. On failure it returns a
+// user-facing error message.
+func completeWith(ctx context.Context, entry chainEntry, req CompletionRequest, hooks generationHooks) (*Completion, string) {
+	provider, err := newProvider(entry.Provider, entry.Model)
+	if err != nil {
+		return nil, fmt.Sprintf("Error creating provider: %v", err)
+	}
+	// Limits wrap the provider inside retries so every attempt is checked
+	provider = withRetry(withLimits(provider, entry, hooks.onLimit), loadRetryPolicy(entry.Provider), hooks.onRetry)
+
+	timeout := loadDurationSetting("timeout", defaultTimeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req.Model = entry.Model
+	req.N = candidatesFor(ctx)
+
+	// Recording skips the cache so every exchange reaches the provider
+	key := cacheKey(entry.Provider, req)
+	if !cacheBypassed(ctx) && !recordingCassettes() {
+		if completion := loadCachedCompletion(key); completion != nil {
+			if hooks.onDelta != nil {
+				hooks.onDelta(completion.Content)
+			}
+			return completion, ""
+		}
+	}
+
+	var completion *Completion
+	if streamer, ok := provider.(StreamingProvider); ok && hooks.onDelta != nil {
+		completion, err = streamer.Stream(ctx, req, hooks.onDelta)
+	} else {
+		completion, err = provider.Complete(ctx, req)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		if cause := context.Cause(ctx); errors.Is(cause, errGenerationTimeout) {
+			return nil, describeError(cause)
+		}
+		return nil, fmt.Sprintf("Error making request: timed out after %s", timeout)
+	}
+	if err != nil {
+		return nil, describeError(err)
+	}
+	if missing := req.N - len(completion.candidates()); missing > 0 {
+		completion.Choices = append(completion.candidates(), completeExtra(ctx, provider, req, missing)...)
+	}
+	saveCachedCompletion(key, completion)
+	if recordingCassettes() && entry.Provider != "replay" {
+		if err := saveCassette(entry.Provider, req, completion); err != nil && hooks.onNotice != nil {
+			hooks.onNotice(fmt.Sprintf("Could not record cassette: %v", err))
+		}
+	}
+	return completion, ""
+}
+
+func loadProviderName() string {
+	return loadSetting("provider", "openrouter")
+}
+
+func saveProviderName(name string) {
+	saveSetting("provider", name)
+}
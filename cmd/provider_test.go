@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGenerationTimeoutBoundsWholeChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Reading the body lets the server notice the client hanging up
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	useTestConfig(t, map[string]string{
+		"fallback_chain":     "openai-compatible:a\nopenai-compatible:b\nopenai-compatible:c",
+		"openai_base_url":    server.URL,
+		"timeout":            "1s",
+		"generation_timeout": "300ms",
+		"max_attempts":       "1",
+	})
+	clearKeyEnv(t)
+
+	ctx, cancel := withGenerationTimeout(context.Background())
+	defer cancel()
+	start := time.Now()
+	result := requestCompletion(ctx, promptRequest("diff", "simple"), generationHooks{})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("generation took %s, want it stopped by generation_timeout", elapsed)
+	}
+	if want := "Error: generation timed out after 300ms (all 3 entries of fallback_chain failed)"; result != want {
+		t.Errorf("result = %q, want %q", result, want)
+	}
+}
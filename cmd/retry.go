@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy controls how failed provider calls are retried.
+type retryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// loadRetryPolicy reads the retry settings for provider. Each setting can be
+// given per provider, e.g. "ollama_max_attempts", or globally.
+func loadRetryPolicy(provider string) retryPolicy {
+	return retryPolicy{
+		MaxAttempts: loadIntSetting(provider+"_max_attempts", loadIntSetting("max_attempts", 4)),
+		BaseDelay:   loadDurationSetting(provider+"_retry_base_delay", loadDurationSetting("retry_base_delay", 500*time.Millisecond)),
+		MaxDelay:    loadDurationSetting(provider+"_retry_max_delay", loadDurationSetting("retry_max_delay", 30*time.Second)),
+	}
+}
+
+// backoff returns the jittered delay before the given retry attempt.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 2)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// retryProvider retries transient failures of the wrapped provider.
+type retryProvider struct {
+	Provider
+	policy  retryPolicy
+	onRetry func(attempt, max int)
+}
+
+// withRetry wraps provider in policy. onRetry, if non-nil, is called before
+// each retry with the upcoming attempt number.
+func withRetry(provider Provider, policy retryPolicy, onRetry func(attempt, max int)) Provider {
+	if policy.MaxAttempts <= 1 {
+		return provider
+	}
+	return &retryProvider{Provider: provider, policy: policy, onRetry: onRetry}
+}
+
+func (p *retryProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	return p.do(ctx, func() (*Completion, bool, error) {
+		completion, err := p.Provider.Complete(ctx, req)
+		return completion, true, err
+	})
+}
+
+func (p *retryProvider) Stream(ctx context.Context, req CompletionRequest, onDelta func(string)) (*Completion, error) {
+	streamer, ok := p.Provider.(StreamingProvider)
+	if !ok {
+		return p.Complete(ctx, req)
+	}
+	return p.do(ctx, func() (*Completion, bool, error) {
+		// Once text has been shown a retry would repeat it, so only calls
+		// that failed before the first token are retried.
+		started := false
+		completion, err := streamer.Stream(ctx, req, func(delta string) {
+			started = true
+			onDelta(delta)
+		})
+		return completion, !started, err
+	})
+}
+
+func (p *retryProvider) do(ctx context.Context, call func() (*Completion, bool, error)) (*Completion, error) {
+	for attempt := 1; ; attempt++ {
+		completion, retryable, err := call()
+		if err == nil || !retryable || attempt >= p.policy.MaxAttempts || !p.shouldRetry(ctx, err) {
+			return completion, err
+		}
+
+		delay := p.policy.backoff(attempt + 1)
+		var perr *ProviderError
+		if errors.As(err, &perr) && perr.RetryAfter > 0 {
+			if perr.RetryAfter > p.policy.MaxDelay {
+				return nil, err
+			}
+			delay = perr.RetryAfter
+		}
+
+		if p.onRetry != nil {
+			p.onRetry(attempt+1, p.policy.MaxAttempts)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// shouldRetry reports whether err is transient: rate limiting, server errors
+// and transport failures that weren't caused by ctx ending.
+func (p *retryProvider) shouldRetry(ctx context.Context, err error) bool {
+	var lerr *limitError
+	if ctx.Err() != nil || errors.As(err, &lerr) {
+		return false
+	}
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		return perr.Kind == ErrRateLimited || perr.Kind == ErrServer
+	}
+	return true
+}
+
+// retryAfter reads how long the server asked us to wait from Retry-After or,
+// failing that, OpenRouter's X-RateLimit-Reset (milliseconds since epoch).
+func retryAfter(header http.Header) time.Duration {
+	var wait time.Duration
+	if value := header.Get("Retry-After"); value != "" {
+		if secs, err := strconv.Atoi(value); err == nil {
+			wait = time.Duration(secs) * time.Second
+		} else if t, err := http.ParseTime(value); err == nil {
+			wait = time.Until(t)
+		}
+	} else if value := header.Get("X-RateLimit-Reset"); value != "" {
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+			wait = time.Until(time.UnixMilli(ms))
+		}
+	}
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
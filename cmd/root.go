@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -18,12 +20,14 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+var initSkipValidate bool
+
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize with OpenRouter API key",
-	Long:  `Set up the API key and default configurations for commiter.`,
+	Long:  `Set up the API key and default configurations for commiter. The key and model are checked against OpenRouter before anything is saved.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		runInit()
+		runInit(initSkipValidate)
 	},
 }
 
@@ -32,11 +36,10 @@ var simpleCommitCmd = &cobra.Command{
 	Short: "Generate and commit with a simple message",
 	Long:  `Automatically generates a short commit message and commits the staged changes.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		message := generateCommitMessage(true)
-		if strings.HasPrefix(message, "Error") || strings.HasPrefix(message, "No") || strings.HasPrefix(message, "API") {
-			fmt.Println(message)
-			os.Exit(1)
-		}
+		ctx, cancel := withGenerationTimeout(cmd.Context())
+		defer cancel()
+		message := generateCommitMessage(ctx, true, cliHooks())
+		exitOnFailure(message)
 		result := performCommit(message, true)
 		fmt.Println(result)
 	},
@@ -47,11 +50,10 @@ var detailedCommitCmd = &cobra.Command{
 	Short: "Generate and commit with a detailed message",
 	Long:  `Automatically generates a detailed commit message with descriptions and commits the staged changes.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		message := generateCommitMessage(false)
-		if strings.HasPrefix(message, "Error") || strings.HasPrefix(message, "No") || strings.HasPrefix(message, "API") {
-			fmt.Println(message)
-			os.Exit(1)
-		}
+		ctx, cancel := withGenerationTimeout(cmd.Context())
+		defer cancel()
+		message := generateCommitMessage(ctx, false, cliHooks())
+		exitOnFailure(message)
 		result := performCommit(message, false)
 		fmt.Println(result)
 	},
@@ -62,23 +64,86 @@ var stashCmd = &cobra.Command{
 	Short: "Generate message and stash changes",
 	Long:  `Automatically generates a stash message and stashes the current changes.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		message := generateStashMessage()
-		if strings.HasPrefix(message, "Error") || strings.HasPrefix(message, "No") || strings.HasPrefix(message, "API") {
-			fmt.Println(message)
-			os.Exit(1)
-		}
+		ctx, cancel := withGenerationTimeout(cmd.Context())
+		defer cancel()
+		message := generateStashMessage(ctx, cliHooks())
+		exitOnFailure(message)
 		result := performStash(message)
 		fmt.Println(result)
 	},
 }
 
+var usageDays int
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report token usage and cost",
+	Long:  `Reports the tokens and cost of generated messages per day, per repo and per model.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUsage(os.Stdout, usageDays)
+	},
+}
+
+var (
+	modelsList    bool
+	modelsRefresh bool
+)
+
+var modelsCmd = &cobra.Command{
+	Use:   "models [filter]",
+	Short: "Browse and select the model",
+	Long:  `Lists the models the selected provider offers with their context length and pricing, and saves the one you pick.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		return runModels(cmd.Context(), filter, modelsList, modelsRefresh)
+	},
+}
+
+// exitOnFailure exits if message is an error description rather than a
+// generated message. Requests refused by a limit get their own exit code.
+func exitOnFailure(message string) {
+	if strings.HasPrefix(message, "Limit exceeded") {
+		fmt.Println(message)
+		os.Exit(exitLimitExceeded)
+	}
+	if strings.HasPrefix(message, "Error") || strings.HasPrefix(message, "No") || strings.HasPrefix(message, "API") {
+		fmt.Println(message)
+		os.Exit(1)
+	}
+}
+
+// cliHooks reports generation notices on stderr for the non-interactive
+// commands, keeping stdout for the result.
+func cliHooks() generationHooks {
+	return generationHooks{
+		onNotice: func(text string) {
+			fmt.Fprintln(os.Stderr, text)
+		},
+	}
+}
+
 func Execute() error {
-	return rootCmd.Execute()
+	// Cancel in-flight generation on ctrl+c in the non-interactive commands
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Always ask the provider instead of reusing a cached message")
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(simpleCommitCmd)
 	rootCmd.AddCommand(detailedCommitCmd)
 	rootCmd.AddCommand(stashCmd)
+	rootCmd.AddCommand(usageCmd)
+	rootCmd.AddCommand(modelsCmd)
+
+	initCmd.Flags().BoolVar(&initSkipValidate, "skip-validate", false, "Save the API key without checking it, e.g. when offline")
+	usageCmd.Flags().IntVar(&usageDays, "days", 30, "Number of days to report on")
+	modelsCmd.Flags().BoolVar(&modelsList, "list", false, "Print the matching models instead of opening the picker")
+	modelsCmd.Flags().BoolVar(&modelsRefresh, "refresh", false, "Fetch the model list even if the cached one is recent")
 }
\ No newline at end of file
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Sampling holds the optional sampling parameters of a request. Nil or zero
+// fields are left to the provider's defaults.
+type Sampling struct {
+	Temperature *float64
+	TopP        *float64
+	MaxTokens   int
+	Seed        *int
+	Stop        []string
+}
+
+// loadSampling reads the sampling parameters for action ("simple",
+// "detailed", "stash" or "summary"). Each setting can be given per action,
+// e.g. "simple_temperature", or globally, e.g. "temperature". Stop sequences
+// are listed one per line.
+func loadSampling(action string) Sampling {
+	setting := func(name string) string {
+		return loadSetting(action+"_"+name, loadSetting(name, ""))
+	}
+
+	var sampling Sampling
+	if value, err := strconv.ParseFloat(setting("temperature"), 64); err == nil {
+		sampling.Temperature = &value
+	}
+	if value, err := strconv.ParseFloat(setting("top_p"), 64); err == nil {
+		sampling.TopP = &value
+	}
+	if value, err := strconv.Atoi(setting("max_tokens")); err == nil && value > 0 {
+		sampling.MaxTokens = value
+	}
+	if value, err := strconv.Atoi(setting("seed")); err == nil {
+		sampling.Seed = &value
+	}
+	for _, stop := range strings.Split(setting("stop"), "\n") {
+		if stop != "" {
+			sampling.Stop = append(sampling.Stop, stop)
+		}
+	}
+	return sampling
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const defaultSummaryPrompt = "Summarize the following part of a Git diff in one to three short sentences, focusing on what changed and why it matters. Output only the summary.\n\n---\nBEGIN GIT DIFF:\n"
+
+// diffChunk is a group of whole files summarized together.
+type diffChunk struct {
+	names []string
+	diff  string
+}
+
+// needsMapReduce reports whether diff is above map_reduce_threshold tokens
+// next to instructions. Without the setting, the threshold is whatever fits
+// the context window.
+func needsMapReduce(diff, instructions string) bool {
+	budget, model := diffBudget(instructions)
+	threshold := loadIntSetting("map_reduce_threshold", budget)
+	return estimateTokens(model, diff) > threshold
+}
+
+// chunkDiff packs the files of diff, in order, into chunks of at most budget
+// tokens. A file too large for a chunk of its own is truncated.
+func chunkDiff(diff string, budget int, estimate func(string) int) []diffChunk {
+	var chunks []diffChunk
+	var current diffChunk
+	used := 0
+
+	for _, file := range parseDiff(diff) {
+		text := file.header + strings.Join(file.hunks, "")
+		cost := estimate(text)
+		if cost > budget {
+			text, _ = truncateDiff(text, budget, estimate)
+			cost = estimate(text)
+		}
+		if used+cost > budget && len(current.names) > 0 {
+			chunks = append(chunks, current)
+			current, used = diffChunk{}, 0
+		}
+		current.names = append(current.names, file.name)
+		current.diff += text
+		used += cost
+	}
+	if len(current.names) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// summaryState is the state of one chunk in a map-reduce generation.
+type summaryState int
+
+const (
+	summaryPending summaryState = iota
+	summaryRunning
+	summaryDone
+	summaryFailed
+)
+
+// summaryProgress reports a state change of one chunk.
+type summaryProgress struct {
+	index int
+	total int
+	files string
+	state summaryState
+}
+
+// generateFromSummaries is the map-reduce generation mode: each chunk of the
+// diff is summarized on its own, up to summary_concurrency at a time, then
+// the detailed commit message is generated from the summaries instead of the
+// diff using request. A chunk whose summary fails is described by its line
+// counts instead. note, naming the files excluded from the diff, is added to
+// the final prompt.
+func generateFromSummaries(ctx context.Context, diff, note string, hooks generationHooks, request func(context.Context, CompletionRequest, generationHooks) string) string {
+	summaryPrompt := loadSetting("summary_prompt", defaultSummaryPrompt)
+	budget, model := diffBudget(summaryPrompt)
+	chunks := chunkDiff(diff, budget, func(text string) int {
+		return estimateTokens(model, text)
+	})
+
+	if hooks.onNotice != nil {
+		hooks.onNotice(fmt.Sprintf("Diff too large for one request; summarized it in %d part(s) first", len(chunks)))
+	}
+	progress := func(index int, state summaryState) {
+		if hooks.onProgress != nil {
+			files := strings.Join(chunks[index].names, ", ")
+			hooks.onProgress(summaryProgress{index: index, total: len(chunks), files: files, state: state})
+		}
+	}
+	for i := range chunks {
+		progress(i, summaryPending)
+	}
+
+	// Summaries are intermediate results: they aren't streamed, and retry or
+	// fallback status from several concurrent calls would only flicker. Limits
+	// still ask before a summary is sent.
+	summaryHooks := generationHooks{onLimit: hooks.onLimit}
+	summaryCtx := withCandidates(ctx, 1)
+
+	summaries := make([]string, len(chunks))
+	failures := make([]string, len(chunks))
+	var g errgroup.Group
+	g.SetLimit(max(loadIntSetting("summary_concurrency", 4), 1))
+	for i, chunk := range chunks {
+		i, chunk := i, chunk
+		g.Go(func() error {
+			progress(i, summaryRunning)
+			completion, failure := completeChain(summaryCtx, promptRequest(summaryPrompt+chunk.diff, "summary"), summaryHooks)
+			if completion == nil {
+				failures[i] = failure
+				progress(i, summaryFailed)
+				return nil
+			}
+			summaries[i] = strings.TrimSpace(completion.Content)
+			progress(i, summaryDone)
+			return nil
+		})
+	}
+	g.Wait()
+
+	if ctx.Err() != nil {
+		return describeError(context.Cause(ctx))
+	}
+
+	sections := make([]summarySection, len(chunks))
+	failed := 0
+	for i, chunk := range chunks {
+		sections[i].names = chunk.names
+		if summaries[i] == "" {
+			failed++
+			sections[i].text = fmt.Sprintf("%s:\n(summary unavailable; %s)\n\n", strings.Join(chunk.names, ", "), lineCounts(chunk.diff))
+			continue
+		}
+		sections[i].text = fmt.Sprintf("%s:\n%s\n\n", strings.Join(chunk.names, ", "), summaries[i])
+	}
+	if failed == len(chunks) {
+		// A refused limit explains the failure better than anything else
+		// and gets the CLI's limit exit code
+		for _, failure := range failures {
+			if strings.HasPrefix(failure, "Limit exceeded") {
+				return failure
+			}
+		}
+		for _, failure := range failures {
+			if failure != "" {
+				return failure
+			}
+		}
+		return "No response from API"
+	}
+	if failed > 0 && hooks.onNotice != nil {
+		hooks.onNotice(fmt.Sprintf("Could not summarize %d of %d part(s); the message is based on the rest and their line counts", failed, len(chunks)))
+	}
+
+	// With enough parts even the summaries can outgrow the context window
+	instructions := loadRegularPrompt() + "(The diff is too large to include. Per-file summaries of it follow.)\n\n"
+	budget, model = diffBudget(instructions + note)
+	combined, omitted := fitSummaries(sections, budget, func(text string) int {
+		return estimateTokens(model, text)
+	})
+	if omitted > 0 && hooks.onNotice != nil {
+		hooks.onNotice(fmt.Sprintf("Summaries too long for the model's context window: left out %d of %d part(s)", omitted, len(sections)))
+	}
+
+	prompt := instructions + combined + note
+	return request(ctx, promptRequest(prompt, "detailed"), hooks)
+}
+
+// summarySection is the summary of one chunk as it appears in the final
+// prompt.
+type summarySection struct {
+	names []string
+	text  string
+}
+
+// fitSummaries joins sections, in order, into at most budget tokens as
+// measured by estimate. Sections that don't fit are replaced by a line
+// naming their files. It returns the text and how many sections were left
+// out.
+func fitSummaries(sections []summarySection, budget int, estimate func(string) int) (string, int) {
+	omittedLine := func(rest []summarySection) string {
+		var names []string
+		for _, section := range rest {
+			names = append(names, section.names...)
+		}
+		return fmt.Sprintf("(summaries of %s omitted)\n", summarizeNames(names))
+	}
+
+	kept := len(sections)
+	used := 0
+	for i, section := range sections {
+		used += estimate(section.text)
+		if used > budget {
+			kept = i
+			break
+		}
+	}
+	if kept == len(sections) {
+		var out strings.Builder
+		for _, section := range sections {
+			out.WriteString(section.text)
+		}
+		return out.String(), 0
+	}
+
+	// Make room for the line naming the omitted files
+	for kept > 0 {
+		used = estimate(omittedLine(sections[kept:]))
+		for _, section := range sections[:kept] {
+			used += estimate(section.text)
+		}
+		if used <= budget {
+			break
+		}
+		kept--
+	}
+
+	var out strings.Builder
+	for _, section := range sections[:kept] {
+		out.WriteString(section.text)
+	}
+	out.WriteString(omittedLine(sections[kept:]))
+	return out.String(), len(sections) - kept
+}
+
+// lineCounts describes a diff by its added and removed lines.
+func lineCounts(diff string) string {
+	added, removed := 0, 0
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return fmt.Sprintf("+%d -%d lines", added, removed)
+}
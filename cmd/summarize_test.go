@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFitSummaries(t *testing.T) {
+	sections := []summarySection{
+		{names: []string{"a.go"}, text: strings.Repeat("a", 40)},
+		{names: []string{"b.go"}, text: strings.Repeat("b", 40)},
+		{names: []string{"c.go"}, text: strings.Repeat("c", 40)},
+	}
+	estimate := func(text string) int { return len(text) }
+
+	text, omitted := fitSummaries(sections, 200, estimate)
+	if omitted != 0 || len(text) != 120 {
+		t.Errorf("fitSummaries with room = (%d chars, %d omitted), want all 120 chars kept", len(text), omitted)
+	}
+
+	text, omitted = fitSummaries(sections, 100, estimate)
+	if omitted != 2 {
+		t.Errorf("omitted = %d, want 2", omitted)
+	}
+	if !strings.Contains(text, "(summaries of b.go, c.go omitted)") {
+		t.Errorf("text = %q, want the omitted files named", text)
+	}
+	if len(text) > 100 {
+		t.Errorf("text is %d chars, want at most the budget of 100", len(text))
+	}
+}
+
+func TestSummariesAskBeforeExceedingLimits(t *testing.T) {
+	useTestConfig(t, map[string]string{
+		"provider":           "replay",
+		"max_request_tokens": "1",
+	})
+	diff := "diff --git a/a.go b/a.go\n@@ -1 +1 @@\n-a\n+b\n" +
+		"diff --git a/b.go b/b.go\n@@ -1 +1 @@\n-a\n+b\n"
+
+	var asked atomic.Int32
+	hooks := generationHooks{onLimit: func(reason string) bool {
+		asked.Add(1)
+		return false
+	}}
+	result := generateFromSummaries(context.Background(), diff, "", hooks, requestCompletion)
+	if !strings.HasPrefix(result, "Limit exceeded") {
+		t.Errorf("result = %q, want the limit to surface", result)
+	}
+	if asked.Load() == 0 {
+		t.Error("onLimit was never asked")
+	}
+}
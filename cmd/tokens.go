@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"strings"
+)
+
+const (
+	defaultContextWindow = 8192
+	// reservedOutputTokens is kept free in the context window for the answer.
+	reservedOutputTokens = 1024
+)
+
+// contextWindows maps model name fragments to context window sizes. The
+// first fragment contained in the model name wins, so more specific
+// fragments come first.
+var contextWindows = []struct {
+	fragment string
+	tokens   int
+}{
+	{"ministral", 128000},
+	{"mistral-large", 128000},
+	{"mistral", 32000},
+	{"claude", 200000},
+	{"gemini", 1000000},
+	{"gpt-4.1", 1000000},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4", 8192},
+	{"gpt-3.5", 16385},
+	{"o1", 128000},
+	{"o3", 200000},
+	{"llama-3.1", 128000},
+	{"llama3.1", 128000},
+	{"llama-3.2", 128000},
+	{"llama3.2", 128000},
+	{"llama", 8192},
+	{"qwen", 32768},
+	{"deepseek", 64000},
+}
+
+// contextWindow returns the context window of model in tokens. The
+// context_window setting overrides the built-in table.
+func contextWindow(model string) int {
+	if tokens := loadIntSetting("context_window", 0); tokens > 0 {
+		return tokens
+	}
+	name := strings.ToLower(model)
+	for _, entry := range contextWindows {
+		if strings.Contains(name, entry.fragment) {
+			return entry.tokens
+		}
+	}
+	return defaultContextWindow
+}
+
+// estimateTokens approximates how many tokens model needs for text. It is a
+// character heuristic, not a tokenizer, so budgets built on it keep slack.
+func estimateTokens(model, text string) int {
+	charsPerToken := 4.0
+	if strings.Contains(strings.ToLower(model), "claude") {
+		charsPerToken = 3.5
+	}
+	return int(float64(len(text))/charsPerToken) + 1
+}
+
+// diffBudget returns how many tokens the diff may use next to instructions,
+// for the most constrained model of the fallback chain, and that model.
+func diffBudget(instructions string) (int, string) {
+	budget, model := -1, ""
+	for _, entry := range loadFallbackChain() {
+		available := contextWindow(entry.Model) - reservedOutputTokens - estimateTokens(entry.Model, instructions)
+		if budget < 0 || available < budget {
+			budget, model = available, entry.Model
+		}
+	}
+	if budget < 0 {
+		budget = 0
+	}
+	return budget, model
+}
+
+// fitDiff truncates diff so that it fits the context window next to
+// instructions. The notice describes what was dropped, if anything.
+func fitDiff(diff, instructions string) (string, string) {
+	budget, model := diffBudget(instructions)
+	return truncateDiff(diff, budget, func(text string) int {
+		return estimateTokens(model, text)
+	})
+}
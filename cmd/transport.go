@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// newHTTPClient returns the client providers send requests with. Overall
+// deadlines come from the request context; the client only bounds
+// establishing the connection. The transport honours the https_proxy,
+// ca_bundle, client_cert/client_key and http_headers settings.
+func newHTTPClient() (*http.Client, error) {
+	connectTimeout := loadDurationSetting("connect_timeout", defaultConnectTimeout)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+
+	// Without the setting the standard HTTPS_PROXY/NO_PROXY variables apply.
+	// The setting only covers https requests, so local servers such as
+	// Ollama are still reached directly, as are NO_PROXY hosts.
+	if proxy := loadSetting("https_proxy", ""); proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid https_proxy %q", proxy)
+		}
+		noProxy := os.Getenv("NO_PROXY")
+		if noProxy == "" {
+			noProxy = os.Getenv("no_proxy")
+		}
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if req.URL.Scheme != "https" || bypassProxy(req.URL, noProxy) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+
+	tlsConfig, err := loadTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	var roundTripper http.RoundTripper = transport
+	if headers := loadHeadersSetting("http_headers"); len(headers) > 0 {
+		roundTripper = &headerTransport{base: transport, headers: headers}
+	}
+	return &http.Client{Transport: roundTripper}, nil
+}
+
+// bypassProxy reports whether u is reached directly: loopback hosts always
+// are, as are the hosts matched by noProxy. noProxy is a comma-separated
+// NO_PROXY list of "*", IPs, CIDR ranges and domains, optionally with a
+// port; a domain, with or without a leading ".", also matches its
+// subdomains.
+func bypassProxy(u *url.URL, noProxy string) bool {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" && u.Scheme == "https" {
+		port = "443"
+	}
+	ip := net.ParseIP(host)
+	if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return true
+	}
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost := entry
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entryHost = h
+		}
+		entryHost = strings.TrimPrefix(strings.TrimPrefix(entryHost, "*"), ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadTLSConfig adds the certificates in ca_bundle to the system pool and
+// loads the client certificate pair, if configured.
+func loadTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if bundle := loadSetting("ca_bundle", ""); bundle != "" {
+		pem, err := os.ReadFile(bundle)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca_bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca_bundle %s", bundle)
+		}
+		config.RootCAs = pool
+	}
+
+	certFile, keyFile := loadSetting("client_cert", ""), loadSetting("client_key", "")
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("client_cert and client_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// headerTransport adds the configured headers to every request. Headers the
+// provider sets itself, such as the auth header, take precedence.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestHTTPSProxyOnlyCoversRemoteHTTPS(t *testing.T) {
+	useTestConfig(t, map[string]string{"https_proxy": "http://proxy.corp:3128"})
+	t.Setenv("NO_PROXY", "internal.corp")
+	t.Setenv("no_proxy", "")
+
+	client, err := newHTTPClient()
+	if err != nil {
+		t.Fatalf("newHTTPClient: %v", err)
+	}
+	transport := client.Transport.(*http.Transport)
+
+	tests := []struct {
+		url       string
+		wantProxy bool
+	}{
+		{"https://openrouter.ai/api/v1/chat/completions", true},
+		{"http://localhost:11434/api/chat", false},
+		{"http://127.0.0.1:8080/v1/chat/completions", false},
+		{"http://gateway.example.com/v1/chat/completions", false},
+		{"https://llm.internal.corp/v1/chat/completions", false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.url, nil)
+		proxy, err := transport.Proxy(req)
+		if err != nil {
+			t.Fatalf("Proxy(%s): %v", tt.url, err)
+		}
+		if (proxy != nil) != tt.wantProxy {
+			t.Errorf("Proxy(%s) = %v, want proxied %v", tt.url, proxy, tt.wantProxy)
+		}
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	tests := []struct {
+		url     string
+		noProxy string
+		want    bool
+	}{
+		{"https://openrouter.ai/api", "", false},
+		{"https://localhost:8443/v1", "", true},
+		{"https://[::1]:8443/v1", "", true},
+		{"https://openrouter.ai/api", "*", true},
+		{"https://llm.corp/v1", "example.com, llm.corp", true},
+		{"https://a.llm.corp/v1", "llm.corp", true},
+		{"https://a.llm.corp/v1", ".llm.corp", true},
+		{"https://a.llm.corp/v1", "*.llm.corp", true},
+		{"https://notllm.corp/v1", "llm.corp", false},
+		{"https://llm.corp/v1", "llm.corp:443", true},
+		{"https://llm.corp:8443/v1", "llm.corp:443", false},
+		{"https://10.1.2.3/v1", "10.0.0.0/8", true},
+		{"https://192.168.1.2/v1", "10.0.0.0/8", false},
+		{"https://10.1.2.3/v1", "10.1.2.3", true},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bypassProxy(u, tt.noProxy); got != tt.want {
+			t.Errorf("bypassProxy(%s, %q) = %v, want %v", tt.url, tt.noProxy, got, tt.want)
+		}
+	}
+}
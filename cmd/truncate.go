@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diffFile is one file section of a unified diff.
+type diffFile struct {
+	name   string
+	header string
+	hunks  []string
+}
+
+// parseDiff splits a git diff into files, each with its header lines (diff
+// --git, index, ---/+++) and its hunks.
+func parseDiff(diff string) []diffFile {
+	var files []diffFile
+	var current *diffFile
+	var hunk strings.Builder
+
+	flushHunk := func() {
+		if current != nil && hunk.Len() > 0 {
+			current.hunks = append(current.hunks, hunk.String())
+			hunk.Reset()
+		}
+	}
+
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if line == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushHunk()
+			files = append(files, diffFile{name: diffFileName(line)})
+			current = &files[len(files)-1]
+			current.header = line
+		case current == nil:
+			// Text before the first file header; keep it as its own section
+			files = append(files, diffFile{})
+			current = &files[len(files)-1]
+			current.header = line
+		case strings.HasPrefix(line, "@@"):
+			flushHunk()
+			hunk.WriteString(line)
+		case hunk.Len() > 0:
+			hunk.WriteString(line)
+		default:
+			current.header += line
+		}
+	}
+	flushHunk()
+	return files
+}
+
+// diffFileName extracts the new path from a "diff --git a/x b/x" line.
+func diffFileName(line string) string {
+	line = strings.TrimSpace(strings.TrimPrefix(line, "diff --git "))
+	if i := strings.LastIndex(line, " b/"); i >= 0 {
+		return line[i+3:]
+	}
+	return line
+}
+
+// hunkDensity is the share of changed lines in a hunk. Hunks that are mostly
+// context say less about the change than dense ones.
+func hunkDensity(hunk string) float64 {
+	lines := strings.Split(strings.TrimSuffix(hunk, "\n"), "\n")
+	changed := 0
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			changed++
+		}
+	}
+	return float64(changed) / float64(len(lines))
+}
+
+// truncateDiff shrinks diff to at most budget tokens as measured by estimate.
+// File headers are kept so the model still sees every changed file; hunks
+// are then added breadth first (every file's first hunk before any second
+// one), densest first. The lines marking what was left out count against
+// the budget too. The returned notice says what was left out.
+func truncateDiff(diff string, budget int, estimate func(string) int) (string, string) {
+	if estimate(diff) <= budget {
+		return diff, ""
+	}
+	files := parseDiff(diff)
+
+	// Each file costs its header plus room for its omitted hunks marker.
+	// Files are dropped from the end until the rest and the line naming the
+	// dropped ones fit.
+	costs := make([]int, len(files))
+	used := 0
+	for i, file := range files {
+		costs[i] = estimate(file.header)
+		if len(file.hunks) > 0 {
+			costs[i] += estimate(omittedHunksLine(len(file.hunks)))
+		}
+		used += costs[i]
+	}
+	droppedCost := func(names []string) int {
+		if len(names) == 0 {
+			return 0
+		}
+		return estimate(omittedFilesLine(names))
+	}
+	var droppedFiles []string
+	for len(files) > 0 && used+droppedCost(droppedFiles) > budget {
+		last := len(files) - 1
+		droppedFiles = append([]string{files[last].name}, droppedFiles...)
+		used -= costs[last]
+		files = files[:last]
+	}
+	used += droppedCost(droppedFiles)
+
+	type candidate struct {
+		file, index, cost int
+		density           float64
+	}
+	var candidates []candidate
+	for f, file := range files {
+		for h, hunk := range file.hunks {
+			candidates = append(candidates, candidate{f, h, estimate(hunk), hunkDensity(hunk)})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].index != candidates[j].index {
+			return candidates[i].index < candidates[j].index
+		}
+		return candidates[i].density > candidates[j].density
+	})
+
+	kept := make(map[[2]int]bool)
+	for _, c := range candidates {
+		if used+c.cost <= budget {
+			kept[[2]int{c.file, c.index}] = true
+			used += c.cost
+		}
+	}
+
+	var out strings.Builder
+	var omittedHunks int
+	var shortened []string
+	for f, file := range files {
+		out.WriteString(file.header)
+		omitted := 0
+		for h, hunk := range file.hunks {
+			if kept[[2]int{f, h}] {
+				out.WriteString(hunk)
+			} else {
+				omitted++
+			}
+		}
+		if omitted > 0 {
+			out.WriteString(omittedHunksLine(omitted))
+			omittedHunks += omitted
+			shortened = append(shortened, file.name)
+		}
+	}
+	out.WriteString(omittedFilesLine(droppedFiles))
+
+	if omittedHunks == 0 && len(droppedFiles) == 0 {
+		return diff, ""
+	}
+	var parts []string
+	if omittedHunks > 0 {
+		parts = append(parts, fmt.Sprintf("omitted %d hunk(s) from %s", omittedHunks, summarizeNames(shortened)))
+	}
+	if len(droppedFiles) > 0 {
+		parts = append(parts, fmt.Sprintf("left out %s entirely", summarizeNames(droppedFiles)))
+	}
+	return out.String(), "Diff truncated to fit the model's context window: " + strings.Join(parts, "; ")
+}
+
+// omittedHunksLine marks where n hunks of a file were left out.
+func omittedHunksLine(n int) string {
+	return fmt.Sprintf("[... %d hunk(s) omitted ...]\n", n)
+}
+
+// omittedFilesLine names the files left out entirely, or is empty if none
+// were.
+func omittedFilesLine(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[... %s omitted ...]\n", summarizeNames(names))
+}
+
+// summarizeNames lists up to three names and counts the rest.
+func summarizeNames(names []string) string {
+	if len(names) <= 3 {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:3], ", "), len(names)-3)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testDiff builds a diff of the named files, each with hunks hunks.
+func testDiff(hunks int, names ...string) string {
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n", name, name, name, name)
+		for h := 0; h < hunks; h++ {
+			fmt.Fprintf(&b, "@@ -%d +%d @@\n-old line %d\n+new line %d\n", h+1, h+1, h, h)
+		}
+	}
+	return b.String()
+}
+
+func TestTruncateDiff(t *testing.T) {
+	estimate := func(text string) int { return len(text) }
+	small := testDiff(1, "a.go")
+	many := testDiff(1, "a.go", "b.go", "c.go", "d.go", "e.go", "f.go")
+
+	tests := []struct {
+		name       string
+		diff       string
+		budget     int
+		want       []string
+		wantNotice string
+		// wantMarkers is how many "[... omitted ...]" lines out has
+		wantMarkers int
+	}{
+		{
+			name:   "fits",
+			diff:   small,
+			budget: len(small),
+			want:   []string{small},
+		},
+		{
+			name:        "hunks omitted",
+			diff:        testDiff(4, "a.go"),
+			budget:      len(testDiff(2, "a.go")) + 10,
+			want:        []string{"diff --git a/a.go", "hunk(s) omitted ...]"},
+			wantNotice:  "omitted",
+			wantMarkers: 1,
+		},
+		{
+			name:        "files dropped into one line",
+			diff:        many,
+			budget:      len(testDiff(1, "a.go", "b.go")) + 40,
+			want:        []string{"diff --git a/a.go", "and 1 more omitted ...]\n"},
+			wantNotice:  "entirely",
+			wantMarkers: 3,
+		},
+		{
+			name:        "only headers fit",
+			diff:        testDiff(3, "a.go", "b.go"),
+			budget:      200,
+			want:        []string{"diff --git a/a.go", "diff --git a/b.go"},
+			wantNotice:  "Diff truncated",
+			wantMarkers: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, notice := truncateDiff(tt.diff, tt.budget, estimate)
+			if len(out) > tt.budget {
+				t.Errorf("output is %d chars, want at most the budget of %d:\n%s", len(out), tt.budget, out)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output lacks %q:\n%s", want, out)
+				}
+			}
+			if !strings.Contains(notice, tt.wantNotice) || (tt.wantNotice == "") != (notice == "") {
+				t.Errorf("notice = %q, want it to mention %q", notice, tt.wantNotice)
+			}
+			if markers := strings.Count(out, "\n[... "); markers != tt.wantMarkers {
+				t.Errorf("output has %d omitted markers, want %d:\n%s", markers, tt.wantMarkers, out)
+			}
+		})
+	}
+}
@@ -1,18 +1,17 @@
 package cmd
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -23,6 +22,72 @@ type model struct {
 	done           bool
 	showingResult  bool
 	quitting       bool
+	generating     bool
+	status         string
+	notice         string
+	progress       []summaryProgress
+	limit          *limitMsg
+	answeredBy     string
+	candidates     []string
+	selected       int
+	generation     int
+	stream         <-chan tea.Msg
+	cancel         context.CancelFunc
+	spinner        spinner.Model
+}
+
+// tokenMsg carries a piece of a message that is still being generated.
+type tokenMsg struct {
+	generation int
+	text       string
+}
+
+// retryMsg reports that a failed provider call is being retried.
+type retryMsg struct {
+	generation   int
+	attempt, max int
+}
+
+// noticeMsg carries something the user should know about the generation,
+// such as parts of the diff that were left out.
+type noticeMsg struct {
+	generation int
+	text       string
+}
+
+// progressMsg reports a state change of one part of a map-reduce
+// generation.
+type progressMsg struct {
+	generation int
+	progress   summaryProgress
+}
+
+// limitMsg asks whether to send a request that exceeds a spending or rate
+// limit. The answer goes to reply.
+type limitMsg struct {
+	generation int
+	reason     string
+	reply      chan<- bool
+}
+
+// fallbackMsg reports that the next entry of the fallback chain is being
+// tried, discarding any partial output of the previous one.
+type fallbackMsg struct {
+	generation int
+	entry      chainEntry
+}
+
+// generatedMsg carries the final message, or an error description, and the
+// provider and model that produced it.
+type generatedMsg struct {
+	generation int
+	result     string
+	answeredBy string
+	candidates []string
+}
+
+func newModel() model {
+	return model{spinner: spinner.New(spinner.WithSpinner(spinner.Dot))}
 }
 
 func (m model) Init() tea.Cmd {
@@ -31,19 +96,118 @@ func (m model) Init() tea.Cmd {
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tokenMsg:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		m.result += msg.text
+		return m, waitForGeneration(m.stream)
+	case retryMsg:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		m.status = fmt.Sprintf("retrying (%d/%d)…", msg.attempt, msg.max)
+		return m, waitForGeneration(m.stream)
+	case noticeMsg:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		m.notice = msg.text
+		return m, waitForGeneration(m.stream)
+	case progressMsg:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		if len(m.progress) != msg.progress.total {
+			m.progress = make([]summaryProgress, msg.progress.total)
+		}
+		m.progress[msg.progress.index] = msg.progress
+		return m, waitForGeneration(m.stream)
+	case limitMsg:
+		if msg.generation != m.generation {
+			msg.reply <- false
+			return m, nil
+		}
+		m.limit = &msg
+		return m, waitForGeneration(m.stream)
+	case fallbackMsg:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		m.result = ""
+		m.status = fmt.Sprintf("falling back to %s…", msg.entry)
+		return m, waitForGeneration(m.stream)
+	case generatedMsg:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		m.result = msg.result
+		m.answeredBy = msg.answeredBy
+		m.candidates = msg.candidates
+		m.selected = 0
+		m.stopGeneration()
+		return m, nil
+	case spinner.TickMsg:
+		if !m.generating {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
 	case tea.KeyMsg:
 		if m.done {
 			return m, tea.Quit
 		}
+		if m.limit != nil {
+			switch {
+			case key.Matches(msg, keys.Yes):
+				m.limit.reply <- true
+				m.limit = nil
+			case key.Matches(msg, keys.No):
+				m.limit.reply <- false
+				m.limit = nil
+			}
+			if !key.Matches(msg, keys.Quit) && !key.Matches(msg, keys.Back) {
+				return m, nil
+			}
+		}
+		if m.generating {
+			switch {
+			case key.Matches(msg, keys.Quit):
+				m.stopGeneration()
+				m.quitting = true
+				return m, tea.Quit
+			case key.Matches(msg, keys.Back):
+				m.stopGeneration()
+				m.choice = ""
+				m.result = ""
+				m.showingResult = false
+				return m, nil
+			}
+			return m, nil
+		}
 		if m.showingResult {
 			switch {
+			case key.Matches(msg, keys.Up):
+				if m.selected > 0 {
+					m.selected--
+				}
+				return m, nil
+			case key.Matches(msg, keys.Down):
+				if m.selected < len(m.candidates)-1 {
+					m.selected++
+				}
+				return m, nil
 			case key.Matches(msg, keys.Confirm):
-				m.result = performAction(m.choice, m.result)
+				message := m.result
+				if len(m.candidates) > 1 {
+					message = m.candidates[m.selected]
+				}
+				m.result = performAction(m.choice, message)
 				m.done = true
 				return m, nil
 			case key.Matches(msg, keys.Redo):
-				m.result = generateMessage(m.choice)
-				return m, nil
+				return m.startGeneration(true)
 			case key.Matches(msg, keys.Back):
 				m.choice = ""
 				m.result = ""
@@ -57,25 +221,140 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, tea.Quit
 			case key.Matches(msg, keys.BigCommit):
 				m.choice = "Big Commit"
-				m.result = generateMessage(m.choice)
-				m.showingResult = true
-				return m, nil
+				return m.startGeneration(false)
 			case key.Matches(msg, keys.ShortCommit):
 				m.choice = "Short Concise Commit"
-				m.result = generateMessage(m.choice)
-				m.showingResult = true
-				return m, nil
+				return m.startGeneration(false)
 			case key.Matches(msg, keys.Stash):
 				m.choice = "Stash with Message"
-				m.result = generateMessage(m.choice)
-				m.showingResult = true
-				return m, nil
+				return m.startGeneration(false)
 			}
 		}
 	}
 	return m, nil
 }
 
+// startGeneration generates a message for m.choice in the background. Tokens
+// arrive as tokenMsg values followed by a single generatedMsg, all tagged
+// with the generation number so messages from a cancelled run are ignored.
+// Redo sets bypassCache so it always asks the provider again.
+func (m model) startGeneration(bypassCache bool) (tea.Model, tea.Cmd) {
+	ctx, cancel := withGenerationTimeout(context.Background())
+	ctx = withCandidates(ctx, loadIntSetting("candidates", defaultCandidates))
+	if bypassCache {
+		ctx = withCacheBypass(ctx)
+	}
+	stream := make(chan tea.Msg)
+	m.generation++
+	generation := m.generation
+
+	go func(choice string) {
+		defer close(stream)
+		send := func(msg tea.Msg) {
+			select {
+			case stream <- msg:
+			case <-ctx.Done():
+			}
+		}
+		var answeredBy string
+		var candidates []string
+
+		// Concurrent summary calls may all hit a limit; ask once per
+		// generation and reuse the answer.
+		var limitMu sync.Mutex
+		var limitAnswered, limitApproved bool
+
+		result := generateMessage(ctx, choice, generationHooks{
+			onDelta: func(delta string) {
+				send(tokenMsg{generation: generation, text: delta})
+			},
+			onRetry: func(attempt, max int) {
+				send(retryMsg{generation: generation, attempt: attempt, max: max})
+			},
+			onNotice: func(text string) {
+				send(noticeMsg{generation: generation, text: text})
+			},
+			onProgress: func(progress summaryProgress) {
+				send(progressMsg{generation: generation, progress: progress})
+			},
+			onFallback: func(entry chainEntry) {
+				send(fallbackMsg{generation: generation, entry: entry})
+			},
+			onLimit: func(reason string) bool {
+				limitMu.Lock()
+				defer limitMu.Unlock()
+				if limitAnswered {
+					return limitApproved
+				}
+				reply := make(chan bool, 1)
+				send(limitMsg{generation: generation, reason: reason, reply: reply})
+				select {
+				case limitApproved = <-reply:
+					limitAnswered = true
+				case <-ctx.Done():
+				}
+				return limitApproved
+			},
+			onAnswer: func(completion *Completion) {
+				candidates = completion.candidates()
+				answeredBy = completion.Provider + ":" + completion.Model
+				if completion.Cached {
+					answeredBy += " (cached)"
+				}
+			},
+		})
+		// The final message may have been cleaned up (e.g. trimmed for
+		// stashes); the first candidate is always that message
+		if len(candidates) > 1 {
+			candidates[0] = result
+			for i := range candidates[1:] {
+				candidates[i+1] = strings.TrimSpace(candidates[i+1])
+			}
+		}
+		send(generatedMsg{generation: generation, result: result, answeredBy: answeredBy, candidates: candidates})
+	}(m.choice)
+
+	m.result = ""
+	m.notice = ""
+	m.limit = nil
+	m.progress = nil
+	m.answeredBy = ""
+	m.candidates = nil
+	m.selected = 0
+	m.status = "Generating message..."
+	m.showingResult = true
+	m.generating = true
+	m.stream = stream
+	m.cancel = cancel
+	return m, tea.Batch(m.spinner.Tick, waitForGeneration(stream))
+}
+
+// stopGeneration cancels the running generation, if any. Messages still in
+// flight from it carry an older generation number and are dropped.
+func (m *model) stopGeneration() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.generation++
+	m.limit = nil
+	m.generating = false
+	m.stream = nil
+	m.cancel = nil
+}
+
+func waitForGeneration(stream <-chan tea.Msg) tea.Cmd {
+	if stream == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		msg, ok := <-stream
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
 func (m model) View() string {
 	if m.quitting {
 		return ""
@@ -83,12 +362,89 @@ func (m model) View() string {
 	if m.done {
 		return m.result + "\n\nPress any key to exit"
 	}
+	if m.limit != nil {
+		warning := lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Render("⚠ Limit exceeded: " + m.limit.reason)
+		return warning + "\n\nSend the request anyway? y - Yes | n - No | b - Back"
+	}
+	if m.generating {
+		if m.result == "" {
+			return m.noticeView() + m.progressView() + m.spinner.View() + " " + m.status
+		}
+		return m.noticeView() + m.result + "\n\n" + m.spinner.View() + " Generating... (b - Back)"
+	}
+	if m.showingResult && len(m.candidates) > 1 {
+		view := m.noticeView() + "Choose a message:\n\n" + m.candidatesView()
+		if m.answeredBy != "" {
+			view += "\n" + lipgloss.NewStyle().Faint(true).Render("via "+m.answeredBy)
+		}
+		return view + "\n\n↑/↓ - Select | c - Confirm | r - Redo | b - Back"
+	}
 	if m.showingResult {
-		return m.result + "\n\nc - Confirm | r - Redo | b - Back"
+		view := m.noticeView() + m.result
+		if m.answeredBy != "" {
+			view += "\n\n" + lipgloss.NewStyle().Faint(true).Render("via "+m.answeredBy)
+		}
+		return view + "\n\nc - Confirm | r - Redo | b - Back"
 	}
 	return mainView()
 }
 
+// noticeView renders the current notice, if any, above the message.
+func (m model) noticeView() string {
+	if m.notice == "" {
+		return ""
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Render("⚠ "+m.notice) + "\n\n"
+}
+
+// candidatesView lists the candidate messages, highlighting the selected one.
+func (m model) candidatesView() string {
+	selected := lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+	var b strings.Builder
+	for i, candidate := range m.candidates {
+		cursor := "  "
+		if i == m.selected {
+			cursor = "> "
+		}
+		lines := strings.Split(strings.TrimSpace(candidate), "\n")
+		for j, line := range lines {
+			if j == 0 {
+				line = fmt.Sprintf("%s%d. %s", cursor, i+1, line)
+			} else {
+				line = "     " + line
+			}
+			if i == m.selected {
+				line = selected.Render(line)
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// progressView lists the parts of a map-reduce generation and their state.
+func (m model) progressView() string {
+	if len(m.progress) == 0 {
+		return ""
+	}
+	faint := lipgloss.NewStyle().Faint(true)
+	var b strings.Builder
+	for _, p := range m.progress {
+		switch p.state {
+		case summaryPending:
+			b.WriteString(faint.Render("  · " + p.files))
+		case summaryRunning:
+			b.WriteString(m.spinner.View() + " " + p.files)
+		case summaryDone:
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("42")).Render("  ✓ ") + p.files)
+		case summaryFailed:
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render("  ✗ ") + p.files)
+		}
+		b.WriteString("\n")
+	}
+	return b.String() + "\n"
+}
+
 func mainView() string {
 	style := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("39"))
 	title := style.Render("Commiter - Choose an action:")
@@ -105,132 +461,90 @@ q - Quit
 
 
 
-func generateMessage(choice string) string {
+// generateMessage generates the message for choice, reporting progress
+// through hooks.
+func generateMessage(ctx context.Context, choice string, hooks generationHooks) string {
 	switch choice {
 	case "Big Commit":
-		return generateCommitMessage(false)
+		return generateCommitMessage(ctx, false, hooks)
 	case "Short Concise Commit":
-		return generateCommitMessage(true)
+		return generateCommitMessage(ctx, true, hooks)
 	case "Stash with Message":
-		return generateStashMessage()
+		return generateStashMessage(ctx, hooks)
 	default:
 		return "Invalid choice"
 	}
 }
 
-func generateCommitMessage(simple bool) string {
-	apiKey := loadAPIKey()
+// stagedDiff returns the output of git diff --staged.
+func stagedDiff(ctx context.Context) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", "diff", "--staged")
+	return cmd.Output()
+}
+
+// fitPromptDiff truncates diff to the model's context window, telling the
+// user through hooks what was left out.
+func fitPromptDiff(diff, instructions string, hooks generationHooks) string {
+	diff, notice := fitDiff(diff, instructions)
+	if notice != "" && hooks.onNotice != nil {
+		hooks.onNotice(notice)
+	}
+	return diff
+}
 
-	// Get git diff --staged
-	cmd := exec.Command("git", "diff", "--staged")
-	diff, err := cmd.Output()
+func generateCommitMessage(ctx context.Context, simple bool, hooks generationHooks) string {
+	staged, err := stagedDiff(ctx)
 	if err != nil {
 		return fmt.Sprintf("Error getting git diff: %v", err)
 	}
-	if len(diff) == 0 {
+	if len(staged) == 0 {
 		return "No staged changes"
 	}
+	diff, excluded := excludeIgnored(string(staged))
+	note := alsoChanged(excluded)
 
-	// Construct prompt
-	var prompt string
-	if simple {
-		prompt = loadSimplePrompt() + string(diff)
-	} else {
-		prompt = loadRegularPrompt() + string(diff)
-	}
-
-	// Call OpenRouter API
-	reqBody := OpenRouterRequest{
-		Model:    loadModel(),
-		Messages: []Message{{Role: "user", Content: prompt}},
+	// Detailed messages are requested as structured output unless disabled
+	request := requestCompletion
+	if !simple && loadSetting("structured_output", "true") == "true" {
+		request = requestCommitMessage
 	}
-	jsonData, _ := json.Marshal(reqBody)
-	req, err := http.NewRequest("POST", "https://openrouter.ai/api/v1/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Sprintf("Error creating request: %v", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Sprintf("Error making request: %v", err)
+	// Diffs too large for the detailed prompt are summarized piecewise
+	// rather than truncated
+	if !simple && needsMapReduce(diff, loadRegularPrompt()) {
+		return generateFromSummaries(ctx, diff, note, hooks, request)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Sprintf("Error reading response: %v", err)
-	}
-	if resp.StatusCode != 200 {
-		return fmt.Sprintf("API error: %s", string(body))
+	// Construct prompt
+	instructions := loadRegularPrompt()
+	if simple {
+		instructions = loadSimplePrompt()
 	}
+	prompt := instructions + fitPromptDiff(diff, instructions+note, hooks) + note
 
-	var orResp OpenRouterResponse
-	json.Unmarshal(body, &orResp)
-	if len(orResp.Choices) == 0 {
-		return "No response from API"
+	action := "detailed"
+	if simple {
+		action = "simple"
 	}
-
-	output := orResp.Choices[0].Message.Content
-
-	return output
+	return request(ctx, promptRequest(prompt, action), hooks)
 }
 
-func generateStashMessage() string {
-	apiKey := loadAPIKey()
-
-	// Get git diff --staged
-	cmd := exec.Command("git", "diff", "--staged")
-	diff, err := cmd.Output()
+func generateStashMessage(ctx context.Context, hooks generationHooks) string {
+	staged, err := stagedDiff(ctx)
 	if err != nil {
 		return fmt.Sprintf("Error getting git diff: %v", err)
 	}
-	if len(diff) == 0 {
+	if len(staged) == 0 {
 		return "No staged changes to stash"
 	}
+	diff, excluded := excludeIgnored(string(staged))
+	note := alsoChanged(excluded)
 
 	// Construct prompt for stash message
-	prompt := loadSimplePrompt() + string(diff)
-
-	// Call OpenRouter API
-	reqBody := OpenRouterRequest{
-		Model:    loadModel(),
-		Messages: []Message{{Role: "user", Content: prompt}},
-	}
-	jsonData, _ := json.Marshal(reqBody)
-	req, err := http.NewRequest("POST", "https://openrouter.ai/api/v1/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Sprintf("Error creating request: %v", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Sprintf("Error making request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Sprintf("Error reading response: %v", err)
-	}
-	if resp.StatusCode != 200 {
-		return fmt.Sprintf("API error: %s", string(body))
-	}
+	instructions := loadSimplePrompt()
+	prompt := instructions + fitPromptDiff(diff, instructions+note, hooks) + note
 
-	var orResp OpenRouterResponse
-	json.Unmarshal(body, &orResp)
-	if len(orResp.Choices) == 0 {
-		return "No response from API"
-	}
-
-	message := strings.TrimSpace(orResp.Choices[0].Message.Content)
-
-	return message
+	return strings.TrimSpace(requestCompletion(ctx, promptRequest(prompt, "stash"), hooks))
 }
 
 func performAction(choice, message string) string {
@@ -287,6 +601,10 @@ var keys = struct {
 	Confirm     key.Binding
 	Redo        key.Binding
 	Back        key.Binding
+	Yes         key.Binding
+	No          key.Binding
+	Up          key.Binding
+	Down        key.Binding
 }{
 	Quit: key.NewBinding(
 		key.WithKeys("q", "ctrl+c"),
@@ -316,10 +634,26 @@ var keys = struct {
 		key.WithKeys("b"),
 		key.WithHelp("b", "back"),
 	),
+	Yes: key.NewBinding(
+		key.WithKeys("y"),
+		key.WithHelp("y", "yes"),
+	),
+	No: key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "no"),
+	),
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "previous"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "next"),
+	),
 }
 
 func runTUI() error {
-	m := model{}
+	m := newModel()
 
 	p := tea.NewProgram(m)
 	_, err := p.Run()
@@ -327,31 +661,76 @@ func runTUI() error {
 }
 
 type initModel struct {
-	apiKey string
-	done   bool
+	apiKey       string
+	model        string
+	skipValidate bool
+	validating   bool
+	credit       string
+	spinner      spinner.Model
+	done         bool
+	err          error
+}
+
+// validatedMsg carries the result of checking the key and model.
+type validatedMsg struct {
+	credit string
 	err    error
 }
 
+func newInitModel(skipValidate bool) initModel {
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	return initModel{model: loadModel(), skipValidate: skipValidate, spinner: s}
+}
+
 func (m initModel) Init() tea.Cmd {
 	return nil
 }
 
 func (m initModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case spinner.TickMsg:
+		if !m.validating {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case validatedMsg:
+		m.validating = false
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.credit = msg.credit
+		m.save()
+		return m, tea.Quit
 	case tea.KeyMsg:
+		if m.validating {
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
 		switch msg.Type {
 		case tea.KeyEnter:
 			if m.apiKey == "" {
 				m.err = fmt.Errorf("API key cannot be empty")
 				return m, nil
 			}
-			// Save the API key and defaults
-			saveAPIKey(m.apiKey)
-			saveSimplePrompt("Generate a short, concise commit message based on the provided Git differences below. Output only the commit message as a single line in lower case. Do not include any additional text, quotes, or explanations.\n\n---\nBEGIN GIT DIFF:\n")
-			saveRegularPrompt("Generate a short, concise commit message based on the provided Git differences below.\nProvide up to 3 additional description options. Output in this exact format:\n\nfeat: commit message\n- desc option 1\n- desc option 2\n- optional desc option 3\n\nDo not include any other text.\n\n---\nBEGIN GIT DIFF:\n")
-			saveModel("mistralai/ministral-3b")
-			m.done = true
-			return m, tea.Quit
+			if m.skipValidate {
+				m.save()
+				return m, tea.Quit
+			}
+			// Nothing is saved until the key and model are known to work
+			m.err = nil
+			m.validating = true
+			apiKey, model := m.apiKey, m.model
+			validate := func() tea.Msg {
+				credit, err := validateOpenRouter(context.Background(), apiKey, model)
+				return validatedMsg{credit: credit, err: err}
+			}
+			return m, tea.Batch(m.spinner.Tick, validate)
 		case tea.KeyBackspace, tea.KeyDelete:
 			if len(m.apiKey) > 0 {
 				m.apiKey = m.apiKey[:len(m.apiKey)-1]
@@ -367,10 +746,26 @@ func (m initModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// save writes the API key, the default prompts and the model. A model
+// chosen earlier is kept.
+func (m *initModel) save() {
+	saveAPIKey(m.apiKey)
+	saveSimplePrompt("Generate a short, concise commit message based on the provided Git differences below. Output only the commit message as a single line in lower case. Do not include any additional text, quotes, or explanations.\n\n---\nBEGIN GIT DIFF:\n")
+	saveRegularPrompt("Generate a short, concise commit message based on the provided Git differences below.\nProvide up to 3 additional description options. Output in this exact format:\n\nfeat: commit message\n- desc option 1\n- desc option 2\n- optional desc option 3\n\nDo not include any other text.\n\n---\nBEGIN GIT DIFF:\n")
+	saveModel(m.model)
+	m.done = true
+}
+
 func (m initModel) View() string {
 	if m.done {
+		if m.credit != "" {
+			return "✅ " + m.credit + "\nSetup complete! You can now use commiter.\nPress any key to exit."
+		}
 		return "✅ Setup complete! You can now use commiter.\nPress any key to exit."
 	}
+	if m.validating {
+		return m.spinner.View() + " Checking the API key and " + m.model + "... (ctrl+c to quit)"
+	}
 
 	var b strings.Builder
 
@@ -404,8 +799,8 @@ func (m initModel) View() string {
 	return b.String()
 }
 
-func runInit() error {
-	m := initModel{}
+func runInit(skipValidate bool) error {
+	m := newInitModel(skipValidate)
 
 	p := tea.NewProgram(m)
 	finalModel, err := p.Run()
@@ -421,26 +816,6 @@ func runInit() error {
 	return nil
 }
 
-// Existing types and functions moved here
-
-type OpenRouterRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-}
-
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type OpenRouterResponse struct {
-	Choices []Choice `json:"choices"`
-}
-
-type Choice struct {
-	Message Message `json:"message"`
-}
-
 func getConfigDir() string {
 	configDir, err := os.UserConfigDir()
 	if err != nil {
@@ -450,15 +825,8 @@ func getConfigDir() string {
 	return filepath.Join(configDir, "commiter")
 }
 
-func loadAPIKey() string {
-	configDir := getConfigDir()
-	keyFile := filepath.Join(configDir, "api_key")
-	data, err := os.ReadFile(keyFile)
-	if err != nil {
-		fmt.Println("API key not found. Run 'commiter init' to set it up.")
-		os.Exit(1)
-	}
-	return strings.TrimSpace(string(data))
+func loadAPIKey() (string, error) {
+	return loadProviderKey("openrouter")
 }
 
 func saveAPIKey(key string) {
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestBackDropsMessagesFromCancelledGeneration(t *testing.T) {
+	useTestConfig(t, nil)
+	_, cancel := context.WithCancel(context.Background())
+	m := newModel()
+	m.choice = "Big Commit"
+	m.generation = 1
+	m.generating = true
+	m.showingResult = true
+	m.stream = make(chan tea.Msg)
+	m.cancel = cancel
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	m = updated.(model)
+	if m.generating || m.showingResult {
+		t.Fatalf("after Back: generating=%v showingResult=%v, want the main menu", m.generating, m.showingResult)
+	}
+
+	updated, cmd := m.Update(tokenMsg{generation: 1, text: "late"})
+	m = updated.(model)
+	if cmd != nil || m.result != "" {
+		t.Errorf("late tokenMsg: cmd=%v result=%q, want it dropped", cmd, m.result)
+	}
+
+	reply := make(chan bool, 1)
+	updated, _ = m.Update(limitMsg{generation: 1, reason: "max_daily_spend", reply: reply})
+	m = updated.(model)
+	if m.limit != nil {
+		t.Error("late limitMsg opened the limit prompt")
+	}
+	if approved := <-reply; approved {
+		t.Error("late limitMsg was approved")
+	}
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// usageRecord is one provider call as stored in usage.jsonl.
+type usageRecord struct {
+	Time             time.Time `json:"time"`
+	Repo             string    `json:"repo"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+}
+
+// usageMu serializes appends from concurrent summary calls.
+var usageMu sync.Mutex
+
+func getUsageFile() string {
+	return filepath.Join(getConfigDir(), "usage.jsonl")
+}
+
+// currentRepo returns the top-level directory of the git repo we're in, or
+// "" outside of one.
+func currentRepo() string {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// recordUsage appends the usage of completion to the usage log. Failures are
+// ignored so accounting never stands in the way of a commit.
+func recordUsage(completion *Completion) {
+	// Providers other than OpenRouter don't report cost; price the tokens
+	// ourselves where the model's price is known
+	cost := completion.Usage.Cost
+	if cost == 0 {
+		if price, ok := priceFor(completion.Provider, completion.Model); ok {
+			cost = price.cost(completion.Usage.PromptTokens, completion.Usage.CompletionTokens)
+		}
+	}
+
+	data, err := json.Marshal(usageRecord{
+		Time:             time.Now(),
+		Repo:             currentRepo(),
+		Provider:         completion.Provider,
+		Model:            completion.Model,
+		PromptTokens:     completion.Usage.PromptTokens,
+		CompletionTokens: completion.Usage.CompletionTokens,
+		Cost:             cost,
+	})
+	if err != nil {
+		return
+	}
+
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	os.MkdirAll(getConfigDir(), 0755)
+	f, err := os.OpenFile(getUsageFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
+
+// loadUsage reads the usage records since the given time.
+func loadUsage(since time.Time) ([]usageRecord, error) {
+	f, err := os.Open(getUsageFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []usageRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record usageRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		if !record.Time.Before(since) {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// usageTotal sums the usage of one group in the report.
+type usageTotal struct {
+	requests         int
+	promptTokens     int
+	completionTokens int
+	cost             float64
+}
+
+// writeUsageTable writes the records grouped by key, sorted by group name.
+func writeUsageTable(w io.Writer, title string, records []usageRecord, key func(usageRecord) string) {
+	totals := map[string]*usageTotal{}
+	for _, record := range records {
+		k := key(record)
+		if totals[k] == nil {
+			totals[k] = &usageTotal{}
+		}
+		totals[k].requests++
+		totals[k].promptTokens += record.PromptTokens
+		totals[k].completionTokens += record.CompletionTokens
+		totals[k].cost += record.Cost
+	}
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "%s\n", title)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tREQUESTS\tPROMPT\tCOMPLETION\tCOST")
+	for _, name := range names {
+		t := totals[name]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t$%.4f\n", name, t.requests, t.promptTokens, t.completionTokens, t.cost)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+// runUsage prints spend per day, repo and model over the last days.
+func runUsage(w io.Writer, days int) error {
+	since := time.Now().AddDate(0, 0, -days)
+	records, err := loadUsage(since)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Fprintf(w, "No usage recorded in the last %d days.\n", days)
+		return nil
+	}
+
+	total := 0.0
+	for _, record := range records {
+		total += record.Cost
+	}
+	fmt.Fprintf(w, "%d requests, $%.4f in the last %d days\n\n", len(records), total, days)
+
+	writeUsageTable(w, "By day", records, func(r usageRecord) string {
+		return r.Time.Local().Format("2006-01-02")
+	})
+	writeUsageTable(w, "By repo", records, func(r usageRecord) string {
+		if r.Repo == "" {
+			return "(none)"
+		}
+		return r.Repo
+	})
+	writeUsageTable(w, "By model", records, func(r usageRecord) string {
+		return r.Provider + ":" + r.Model
+	})
+	return nil
+}
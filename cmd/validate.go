@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	openRouterKeyURL = "https://openrouter.ai/api/v1/key"
+	// validateTimeout bounds the checks init runs before saving.
+	validateTimeout = 30 * time.Second
+)
+
+// openRouterKey is the response of OpenRouter's key endpoint. Limit and
+// LimitRemaining are null for keys without a credit limit.
+type openRouterKey struct {
+	Data struct {
+		Label          string   `json:"label"`
+		Usage          float64  `json:"usage"`
+		Limit          *float64 `json:"limit"`
+		LimitRemaining *float64 `json:"limit_remaining"`
+		IsFreeTier     bool     `json:"is_free_tier"`
+	} `json:"data"`
+}
+
+// validateOpenRouter checks that apiKey is accepted by OpenRouter and that
+// model answers a tiny test completion. It returns a summary of the key's
+// credit for init to show.
+func validateOpenRouter(ctx context.Context, apiKey, model string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, validateTimeout)
+	defer cancel()
+
+	client, err := newHTTPClient()
+	if err != nil {
+		return "", err
+	}
+
+	var key openRouterKey
+	headers := map[string]string{"Authorization": "Bearer " + apiKey}
+	if err := getJSON(ctx, client, "openrouter", openRouterKeyURL, headers, &key); err != nil {
+		return "", validationError("checking API key", err)
+	}
+
+	provider := newOpenRouterProvider(apiKey, client)
+	_, err = provider.Complete(ctx, CompletionRequest{
+		Model:    model,
+		Messages: []Message{{Role: "user", Content: "Reply with OK."}},
+		Sampling: Sampling{MaxTokens: 5},
+	})
+	if err != nil {
+		return "", validationError("testing model "+model, err)
+	}
+
+	credit := fmt.Sprintf("$%.4f used, no credit limit", key.Data.Usage)
+	if key.Data.LimitRemaining != nil {
+		credit = fmt.Sprintf("$%.4f of credit remaining", *key.Data.LimitRemaining)
+	}
+	if key.Data.IsFreeTier {
+		credit += " (free tier)"
+	}
+	return fmt.Sprintf("API key and model %s work: %s.", model, credit), nil
+}
+
+// validationError describes a failed init check, spelling out rejected keys.
+func validationError(step string, err error) error {
+	var perr *ProviderError
+	if errors.As(err, &perr) && perr.Kind == ErrAuth {
+		return fmt.Errorf("%s: OpenRouter rejected the API key", step)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s: timed out after %s", step, validateTimeout)
+	}
+	return fmt.Errorf("%s: %s", step, describeError(err))
+}